@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+	"github.com/lhemerly/Constellation/topology"
+)
+
+// newFlagSet creates a flag set for a command that reports errors instead
+// of exiting.
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: constellation %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses a command's flags and returns its positional arguments,
+// of which there must be at least min.
+func parseFlags(fs *flag.FlagSet, args []string, min int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, &usageError{err.Error()}
+	}
+	if fs.NArg() < min {
+		fs.Usage()
+		return nil, &usageError{"missing topology file"}
+	}
+	return fs.Args(), nil
+}
+
+func runCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "run", "FILE")
+	input := fs.String("input", "", "read events from standard input and inject them into node `ID`, stopping at end of input")
+	drain := fs.Duration("drain", 10*time.Second, "how long to wait for in-flight events on shutdown")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	rt, err := topology.Load(positional[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "running %d nodes from %s\n", rt.Len(), positional[0])
+	if *input != "" {
+		err = injectLines(ctx, rt, *input, e.stdin, e.stderr)
+	} else {
+		<-ctx.Done()
+	}
+	return errors.Join(err, shutdown(rt, *drain))
+}
+
+func validateCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "validate", "FILE...")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, path := range positional {
+		t, err := topology.ParseFile(path)
+		if err != nil {
+			fmt.Fprintln(e.stderr, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(e.stdout, "%s: ok (%d nodes, %d subscriptions)\n", path, len(t.Nodes), len(t.Subscriptions))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files invalid", failed, len(positional))
+	}
+	return nil
+}
+
+func graphCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "graph", "FILE")
+	format := fs.String("format", "text", "output `format`: text, dot or mermaid")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	t, err := topology.ParseFile(positional[0])
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+	case "dot", "mermaid":
+		rt, err := t.Build()
+		if err != nil {
+			return err
+		}
+		if *format == "dot" {
+			return node.ExportDOT(e.stdout, rt.Nodes()...)
+		}
+		return node.ExportMermaid(e.stdout, rt.Nodes()...)
+	default:
+		return &usageError{fmt.Sprintf("unknown format %q", *format)}
+	}
+	fmt.Fprintln(e.stdout, "nodes:")
+	for _, n := range t.Nodes {
+		fmt.Fprintf(e.stdout, "  %s (%s)\n", n.ID, n.Kind)
+	}
+	fmt.Fprintln(e.stdout, "subscriptions:")
+	for _, s := range t.Subscriptions {
+		if s.Filter != "" {
+			fmt.Fprintf(e.stdout, "  %s -> %s [%s]\n", s.From, s.To, s.Filter)
+		} else {
+			fmt.Fprintf(e.stdout, "  %s -> %s\n", s.From, s.To)
+		}
+	}
+	return nil
+}
+
+func publishCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "publish", "FILE [EVENT...]")
+	target := fs.String("node", "", "inject events into node `ID` (required)")
+	drain := fs.Duration("drain", 10*time.Second, "how long to wait for in-flight events on shutdown")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *target == "" {
+		return &usageError{"-node is required"}
+	}
+
+	rt, err := topology.Load(positional[0])
+	if err != nil {
+		return err
+	}
+	if events := positional[1:]; len(events) > 0 {
+		for _, event := range events {
+			if err = inject(rt, *target, []byte(event)); err != nil {
+				break
+			}
+		}
+	} else {
+		err = injectLines(ctx, rt, *target, e.stdin, e.stderr)
+	}
+	return errors.Join(err, shutdown(rt, *drain))
+}
+
+func tailCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "tail", "FILE")
+	target := fs.String("node", "", "print the output of node `ID` (required)")
+	input := fs.String("input", "", "read events from standard input and inject them into node `ID`, stopping at end of input")
+	source := fs.Bool("source", false, "prefix each event with the ID of the node that published it")
+	drain := fs.Duration("drain", 10*time.Second, "how long to wait for in-flight events on shutdown")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *target == "" {
+		return &usageError{"-node is required"}
+	}
+
+	rt, err := topology.Load(positional[0])
+	if err != nil {
+		return err
+	}
+	if rt.Get(*target) == nil {
+		return errors.Join(fmt.Errorf("%s: %w", *target, node.ErrNodeNotFound), shutdown(rt, *drain))
+	}
+
+	printer := node.NewBaseNode(uniqueID(rt, "constellation-tail"))
+	var mu sync.Mutex
+	printer.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if *source {
+			fmt.Fprintf(e.stdout, "%s\t", msg.Source)
+		}
+		e.stdout.Write(msg.Payload)
+		io.WriteString(e.stdout, "\n")
+		return msg, nil
+	})
+	if err := rt.Add(printer); err != nil {
+		return errors.Join(err, shutdown(rt, *drain))
+	}
+	if err := rt.Connect(*target, printer.GetID()); err != nil {
+		return errors.Join(err, shutdown(rt, *drain))
+	}
+
+	if *input != "" {
+		err = injectLines(ctx, rt, *input, e.stdin, e.stderr)
+	} else {
+		<-ctx.Done()
+	}
+	return errors.Join(err, shutdown(rt, *drain))
+}
+
+// inject passes an event to a node and publishes the node's output to its
+// subscribers, as if the event had arrived from upstream. Nodes that forward
+// their output publish it themselves.
+func inject(rt *node.Runtime, id string, event []byte) error {
+	n := rt.Get(id)
+	if n == nil {
+		return fmt.Errorf("%s: %w", id, node.ErrNodeNotFound)
+	}
+	if mn, ok := n.(node.MessageNode); ok {
+		out, err := mn.ProcessMessage(node.NewMessage("", event))
+		if err != nil || out == nil || forwards(n) {
+			return err
+		}
+		var errs []error
+		for _, msg := range out.Batch() {
+			errs = append(errs, mn.NotifyMessage(msg))
+		}
+		return errors.Join(errs...)
+	}
+	out, err := n.Process(event)
+	if err != nil || out == nil {
+		return err
+	}
+	return n.Notify(out)
+}
+
+// forwards reports whether n publishes its output on its own.
+func forwards(n node.Node) bool {
+	f, ok := n.(interface {
+		GetForwarding() (node.ForwardConfig, bool)
+	})
+	if !ok {
+		return false
+	}
+	_, enabled := f.GetForwarding()
+	return enabled
+}
+
+// injectLines injects each line of r into a node until r is exhausted or
+// ctx is done. Events that fail are reported to errOut and do not stop
+// the loop.
+func injectLines(ctx context.Context, rt *node.Runtime, id string, r io.Reader, errOut io.Writer) error {
+	if rt.Get(id) == nil {
+		return fmt.Errorf("%s: %w", id, node.ErrNodeNotFound)
+	}
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	failed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				err := <-scanErr
+				if failed > 0 {
+					err = errors.Join(err, fmt.Errorf("%d events failed", failed))
+				}
+				return err
+			}
+			if line == "" {
+				continue
+			}
+			if err := inject(rt, id, []byte(line)); err != nil {
+				fmt.Fprintf(errOut, "inject %s: %v\n", id, err)
+				failed++
+			}
+		}
+	}
+}
+
+// shutdown stops a runtime, draining nodes for at most timeout.
+func shutdown(rt *node.Runtime, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return rt.Shutdown(ctx)
+}
+
+// uniqueID returns base, or base with a numeric suffix, such that no node
+// in rt has that ID.
+func uniqueID(rt *node.Runtime, base string) string {
+	id := base
+	for i := 2; rt.Get(id) != nil; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	return id
+}
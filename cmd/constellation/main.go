@@ -0,0 +1,103 @@
+// Command constellation runs and inspects topology files.
+//
+// Usage:
+//
+//	constellation <command> [flags] FILE [args]
+//
+// The commands are:
+//
+//	run       start a topology and run it until interrupted
+//	validate  check one or more topology files
+//	graph     print the nodes and subscriptions of a topology
+//	publish   start a topology and inject events into a node
+//	tail      start a topology and print the output of a node
+//
+// Commands that take events read them from their arguments or, when there
+// are none, one per line from standard input. Only the node kinds built
+// into the topology package are available.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// command is a constellation subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = []command{
+	{"run", "start a topology and run it until interrupted", runCommand},
+	{"validate", "check one or more topology files", validateCommand},
+	{"graph", "print the nodes and subscriptions of a topology", graphCommand},
+	{"publish", "start a topology and inject events into a node", publishCommand},
+	{"tail", "start a topology and print the output of a node", tailCommand},
+}
+
+// env holds a command's standard streams.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// usageError is returned for invalid command lines.
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// run executes a command line and returns the process exit code: 0 on
+// success, 1 on failure and 2 for usage errors.
+func run(ctx context.Context, e *env, args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(e.stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(ctx, e, args[1:])
+		if err == nil || errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(e.stderr, "constellation %s: %v\n", cmd.name, err)
+		if _, ok := err.(*usageError); ok {
+			return 2
+		}
+		return 1
+	}
+	fmt.Fprintf(e.stderr, "constellation: unknown command %q\n", args[0])
+	usage(e.stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: constellation <command> [flags] FILE [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'constellation <command> -h' for a command's flags.")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+const testTopology = `nodes:
+  - id: ingest
+    kind: base
+  - id: hot
+    kind: base
+subscriptions:
+  - from: ingest
+    to: hot
+    filter: 'temp > 25'
+`
+
+func writeTopology(t *testing.T, doc string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "topology.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCLI(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out, errOut bytes.Buffer
+	code = run(ctx, &env{stdin: strings.NewReader(stdin), stdout: &out, stderr: &errOut}, args)
+	return code, out.String(), errOut.String()
+}
+
+func TestValidate(t *testing.T) {
+	good := writeTopology(t, testTopology)
+	bad := writeTopology(t, "nodes:\n  - id: a\n    kind: nope\n")
+
+	code, stdout, _ := runCLI(t, "", "validate", good)
+	if code != 0 || !strings.Contains(stdout, "ok (2 nodes, 1 subscriptions)") {
+		t.Errorf("validate good: code = %d, stdout = %q", code, stdout)
+	}
+	code, _, stderr := runCLI(t, "", "validate", good, bad)
+	if code != 1 || !strings.Contains(stderr, bad+`:2: node "a": unknown kind "nope"`) {
+		t.Errorf("validate bad: code = %d, stderr = %q", code, stderr)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	path := writeTopology(t, testTopology)
+	code, stdout, _ := runCLI(t, "", "graph", path)
+	if code != 0 || !strings.Contains(stdout, "ingest -> hot [temp > 25]") || !strings.Contains(stdout, "hot (base)") {
+		t.Errorf("graph: code = %d, stdout = %q", code, stdout)
+	}
+	code, stdout, _ = runCLI(t, "", "graph", "-format", "dot", path)
+	if code != 0 || !strings.Contains(stdout, `"ingest" -> "hot" [label="0 events"];`) {
+		t.Errorf("graph -format dot: code = %d, stdout = %q", code, stdout)
+	}
+	code, stdout, _ = runCLI(t, "", "graph", "-format", "mermaid", path)
+	if code != 0 || !strings.Contains(stdout, `n1 -->|"0 events"| n0`) {
+		t.Errorf("graph -format mermaid: code = %d, stdout = %q", code, stdout)
+	}
+	if code, _, _ := runCLI(t, "", "graph", "-format", "png", path); code != 2 {
+		t.Errorf("graph -format png: code = %d, want 2", code)
+	}
+}
+
+func TestTailAndPublish(t *testing.T) {
+	path := writeTopology(t, testTopology)
+	events := "{\"temp\": 30}\n{\"temp\": 20}\n{\"temp\": 40}\n"
+
+	code, stdout, stderr := runCLI(t, events, "tail", "-node", "ingest", "-input", "ingest", "-source", path)
+	if code != 0 {
+		t.Fatalf("tail: code = %d, stderr = %q", code, stderr)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 3 || lines[0] != "ingest\t{\"temp\": 30}" {
+		t.Errorf("tail ingest output = %q", stdout)
+	}
+
+	code, _, stderr = runCLI(t, "", "publish", "-node", "ingest", path, `{"temp": 30}`)
+	if code != 0 {
+		t.Errorf("publish: code = %d, stderr = %q", code, stderr)
+	}
+	code, _, stderr = runCLI(t, "", "publish", "-node", "missing", path, "x")
+	if code != 1 || !strings.Contains(stderr, "missing: node not found") {
+		t.Errorf("publish to missing node: code = %d, stderr = %q", code, stderr)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	path := writeTopology(t, testTopology)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	var errOut bytes.Buffer
+	go func() {
+		done <- run(ctx, &env{stdin: strings.NewReader(""), stdout: &bytes.Buffer{}, stderr: &errOut}, []string{"run", path})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("run: code = %d, stderr = %q", code, errOut.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not stop after cancellation")
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{{}, {"frobnicate"}, {"publish", "file.yaml"}, {"graph"}} {
+		if code, _, _ := runCLI(t, "", args...); code != 2 {
+			t.Errorf("%v: code = %d, want 2", args, code)
+		}
+	}
+	if code, _, _ := runCLI(t, "", "run", "-h"); code != 0 {
+		t.Errorf("run -h: code = %d, want 0", code)
+	}
+}
+
+func TestInjectForwardingNode(t *testing.T) {
+	rt := node.NewRuntime()
+	source, sink := node.NewBaseNode("source"), node.NewBaseNode("sink")
+	source.SetForwarding(node.ForwardConfig{})
+	for _, n := range []*node.BaseNode{source, sink} {
+		if err := rt.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rt.Connect("source", "sink"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Shutdown(context.Background())
+
+	if err := inject(rt, "source", []byte("event")); err != nil {
+		t.Fatalf("inject() error = %v", err)
+	}
+	if got := sink.GetEventCount(); got != 1 {
+		t.Errorf("sink received %d events, want 1", got)
+	}
+}
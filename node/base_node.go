@@ -1,101 +1,442 @@
 package node
 
 import (
-    "sync"
-    "sync/atomic"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // BaseNode provides common functionality for all node types.
 type BaseNode struct {
-    id            string
-    subscriptions map[string]Node
-    mutex         sync.RWMutex
-    processFunc   func([]byte) ([]byte, error)
-    eventCounter  uint64 // Atomic counter for received events
+	id            string
+	subscriptions map[string]*subscription
+	mutex         sync.RWMutex
+	handler       MessageFunc
+	middleware    []Middleware
+	chain         MessageFunc // handler wrapped by middleware
+	eventCounter  uint64      // Atomic counter for received events
+	panicCounter  uint64      // Atomic counter for recovered panics
+	strikes       uint64      // Atomic count of panics since the last release
+	panicPolicy   PanicPolicy
+	quarantined   int32 // Atomic flag set when the node is quarantined
+	deadLetters   DeadLetterSink
+	limiter       *RateLimiter
+	state         State
+	startHooks    []func() error
+	stopHooks     []func() error
+	publishers    map[string]*BaseNode // nodes this node is subscribed to
+	inflight      inFlight
+	pausePolicy   PausePolicy
+	paused        []InFlight // events buffered while paused
+	resumeMu      sync.Mutex // serializes Resume
+	cycleCheck    bool
+	maxHops       int
+	forward       *ForwardConfig // nil unless output is forwarded
+	executor      executor       // nil runs messages on the caller's goroutine
+	store         *KV            // created on first use of KV
 }
 
 // NewBaseNode creates a new BaseNode with a given ID.
 func NewBaseNode(id string) *BaseNode {
-    return &BaseNode{
-        id:            id,
-        subscriptions: make(map[string]Node),
-        processFunc: func(input []byte) ([]byte, error) {
-            return input, nil // Default echo behavior
-        },
-    }
+	n := &BaseNode{
+		id:            id,
+		subscriptions: make(map[string]*subscription),
+		publishers:    make(map[string]*BaseNode),
+		handler: func(msg *Message) (*Message, error) {
+			return msg, nil // Default echo behavior
+		},
+	}
+	n.chain = n.handler
+	return n
 }
 
-// Create initializes the node, setting up any necessary resources.
+// Create initializes the node, running its OnStart hooks, and moves it to
+// the running state. If a hook fails the node moves to the failed state.
 func (n *BaseNode) Create() error {
-    // Initialization logic, if any.
-    return nil
+	if err := n.transition(StateStarting); err != nil {
+		return err
+	}
+	if err := runHooks(n.hooks(true), true); err != nil {
+		n.forceState(StateFailed)
+		return fmt.Errorf("node %s: start: %w", n.id, err)
+	}
+	return n.transition(StateRunning)
 }
 
-// Delete removes the node, releasing any resources.
+// Delete removes the node, detaching it from every node it is subscribed to,
+// waiting for in-flight work to finish and running its OnStop hooks. If a
+// hook fails the node moves to the failed state. Use DeleteContext to bound
+// the wait.
 func (n *BaseNode) Delete() error {
-    // Cleanup logic, if any.
-    return nil
+	_, err := n.DeleteContext(context.Background())
+	return err
 }
 
 // Process processes the input and returns the output.
 func (n *BaseNode) Process(input []byte) ([]byte, error) {
-    atomic.AddUint64(&n.eventCounter, 1)
-    return n.processFunc(input)
+	out, err := n.ProcessMessage(NewMessage("", input))
+	if out == nil {
+		return nil, err
+	}
+	return out.Payload, err
+}
+
+// ProcessMessage processes the message and returns the output message.
+// A panic in the process function is recovered and handled according to the
+// node's PanicPolicy. If the node has a rate limit, messages beyond it wait,
+// fail with ErrRateLimited, or are dropped with a nil output and error.
+// While the node is paused, messages are buffered with a nil output and
+// error, or rejected, according to its PausePolicy. With forwarding enabled
+// by SetForwarding, the output is also published to the node's subscribers.
+func (n *BaseNode) ProcessMessage(msg *Message) (*Message, error) {
+	if n.IsQuarantined() {
+		return nil, fmt.Errorf("node %s: %w", n.id, ErrQuarantined)
+	}
+	n.mutex.RLock()
+	chain, limiter, forward, exec, state := n.chain, n.limiter, n.forward, n.executor, n.state
+	if state == StatePaused {
+		n.mutex.RUnlock()
+		return n.hold(msg)
+	}
+	if state != StateRunning {
+		n.mutex.RUnlock()
+		return nil, &StateError{NodeID: n.id, State: state}
+	}
+	key := n.inflight.add(msg, "")
+	n.mutex.RUnlock()
+	defer n.inflight.done(key)
+	return n.execute(exec, msg, chain, limiter, forward)
+}
+
+// executor runs an admitted message, for example on a worker goroutine,
+// and returns its result.
+type executor func(msg *Message, run func() (*Message, error)) (*Message, error)
+
+// execute processes an admitted message with exec, if set.
+func (n *BaseNode) execute(exec executor, msg *Message, chain MessageFunc, limiter *RateLimiter, forward *ForwardConfig) (*Message, error) {
+	run := func() (*Message, error) { return n.process(msg, chain, limiter, forward) }
+	if exec == nil {
+		return run()
+	}
+	return exec(msg, run)
+}
+
+// process runs a message through the rate limiter and processing chain and
+// forwards the output if forward is set.
+func (n *BaseNode) process(msg *Message, chain MessageFunc, limiter *RateLimiter, forward *ForwardConfig) (*Message, error) {
+	if limiter != nil {
+		if err := limiter.acquire(); err == errSampledOut {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("node %s: %w", n.id, err)
+		}
+	}
+	atomic.AddUint64(&n.eventCounter, 1)
+
+	out, err := n.run(msg, chain)
+	if err != nil || forward == nil {
+		return out, err
+	}
+	return out, n.forwardOutput(forward, msg, out)
+}
+
+// run calls the processing chain, recovering panics.
+func (n *BaseNode) run(msg *Message, chain MessageFunc) (out *Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, n.recoverPanic(r)
+		}
+	}()
+	return chain(msg)
+}
+
+// recoverPanic records a recovered panic and applies the panic policy.
+func (n *BaseNode) recoverPanic(value interface{}) error {
+	perr := newPanicError(n.id, value)
+	atomic.AddUint64(&n.panicCounter, 1)
+	count := atomic.AddUint64(&n.strikes, 1)
+
+	n.mutex.RLock()
+	policy := n.panicPolicy
+	n.mutex.RUnlock()
+
+	switch policy.Action {
+	case PanicQuarantine:
+		if count >= policy.QuarantineAfter {
+			atomic.StoreInt32(&n.quarantined, 1)
+		}
+	case PanicRethrow:
+		panic(perr)
+	}
+	return perr
+}
+
+// SetPanicPolicy sets how the node handles panics in its process function.
+func (n *BaseNode) SetPanicPolicy(policy PanicPolicy) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.panicPolicy = policy
+}
+
+// GetPanicCount returns the number of panics recovered by this node.
+func (n *BaseNode) GetPanicCount() uint64 {
+	return atomic.LoadUint64(&n.panicCounter)
+}
+
+// IsQuarantined reports whether the node has been quarantined.
+func (n *BaseNode) IsQuarantined() bool {
+	return atomic.LoadInt32(&n.quarantined) == 1
+}
+
+// ReleaseQuarantine lets a quarantined node process events again. Panics
+// before the release no longer count towards QuarantineAfter;
+// GetPanicCount keeps counting them.
+func (n *BaseNode) ReleaseQuarantine() {
+	atomic.StoreUint64(&n.strikes, 0)
+	atomic.StoreInt32(&n.quarantined, 0)
+}
+
+// SetRateLimit limits the rate at which the node processes events. Calling
+// it again adjusts the existing limit; a zero Rate removes the limit.
+func (n *BaseNode) SetRateLimit(limit RateLimit) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.limiter == nil {
+		n.limiter = NewRateLimiter(limit)
+		return
+	}
+	n.limiter.SetLimit(limit)
+}
+
+// GetRateLimiter returns the node's rate limiter, or nil if it has none.
+func (n *BaseNode) GetRateLimiter() *RateLimiter {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.limiter
+}
+
+// GetSubscriptionRateLimiter returns the rate limiter of a subscription, or
+// nil if the node has no such subscription or it is not rate limited. The
+// limit can be adjusted at runtime with SetLimit.
+func (n *BaseNode) GetSubscriptionRateLimiter(id string) *RateLimiter {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if sub, ok := n.subscriptions[id]; ok {
+		return sub.limiter
+	}
+	return nil
 }
 
 // SetProcessFunc allows setting a custom process function.
-func (n *BaseNode) SetProcessFunc(processFunc func([]byte) ([]byte, error)) {
-    n.mutex.Lock()
-    defer n.mutex.Unlock()
-    n.processFunc = processFunc
+func (n *BaseNode) SetProcessFunc(processFunc ProcessFunc) {
+	n.SetMessageFunc(AdaptProcessFunc(processFunc))
+}
+
+// SetMessageFunc allows setting a custom process function that receives the
+// full message, including its source, timestamp and headers. Middleware
+// registered with Use keeps wrapping the new function.
+func (n *BaseNode) SetMessageFunc(messageFunc MessageFunc) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.handler = messageFunc
+	n.chain = chainMiddleware(n.handler, n.middleware)
+}
+
+// Use appends middleware to the node's processing chain. Middleware runs in
+// registration order: the first registered is the outermost and sees each
+// message first. Use may be called while the node is processing; calls
+// already in progress finish with the chain they started with.
+func (n *BaseNode) Use(middleware ...Middleware) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.middleware = append(n.middleware, middleware...)
+	n.chain = chainMiddleware(n.handler, n.middleware)
+}
+
+// ResetMiddleware removes all middleware from the node's processing chain.
+func (n *BaseNode) ResetMiddleware() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.middleware = nil
+	n.chain = n.handler
 }
 
 // Subscribe adds a node to the subscription list for event notifications.
-func (n *BaseNode) Subscribe(node Node) error {
-    n.mutex.Lock()
-    defer n.mutex.Unlock()
-    n.subscriptions[node.GetID()] = node
-    return nil
+// Options such as WithFilter restrict which events the node receives.
+// Subscribing the same node again replaces its options; subscribing a
+// different node with the ID of an existing subscriber fails with
+// ErrDuplicateID.
+func (n *BaseNode) Subscribe(node Node, opts ...SubscriptionOption) error {
+	sub := &subscription{node: node, subscribedAt: time.Now()}
+	for _, opt := range opts {
+		if err := opt(sub); err != nil {
+			return fmt.Errorf("subscribe %s: %w", node.GetID(), err)
+		}
+	}
+
+	if sub.breaker != nil {
+		sub.breaker.publisherID = n.id
+	}
+
+	n.mutex.RLock()
+	cycleCheck := n.cycleCheck
+	n.mutex.RUnlock()
+	if cycleCheck {
+		if err := n.checkCycle(node); err != nil {
+			return err
+		}
+	}
+
+	n.mutex.Lock()
+	if existing, ok := n.subscriptions[node.GetID()]; ok && !sameNode(existing.node, node) {
+		n.mutex.Unlock()
+		return fmt.Errorf("subscribe %s: %w", node.GetID(), ErrDuplicateID)
+	}
+	n.subscriptions[node.GetID()] = sub
+	n.mutex.Unlock()
+
+	if b, ok := node.(baseNoder); ok {
+		b.base().attachPublisher(n)
+	}
+	return nil
 }
 
 // Unsubscribe removes a node from the subscription list.
 func (n *BaseNode) Unsubscribe(node Node) error {
-    n.mutex.Lock()
-    defer n.mutex.Unlock()
-    delete(n.subscriptions, node.GetID())
-    return nil
+	n.removeSubscription(node.GetID())
+	if b, ok := node.(baseNoder); ok {
+		b.base().detachPublisher(n)
+	}
+	return nil
 }
 
-// Notify sends an event to all subscribed nodes and waits for all to complete.
+// removeSubscription deletes a subscription by subscriber ID.
+func (n *BaseNode) removeSubscription(id string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.subscriptions, id)
+}
+
+// Notify sends an event to all subscribed nodes whose filter matches and waits
+// for all to complete. The event is a new message, starting at zero hops.
 func (n *BaseNode) Notify(event []byte) error {
-    n.mutex.RLock()
-    defer n.mutex.RUnlock()
+	return n.NotifyMessage(NewMessage(n.id, event))
+}
+
+// NotifyMessage sends a message to all subscribed nodes whose filter matches
+// and waits for all to complete. Each subscriber receives its own copy of the
+// message with Source set to this node's ID. Subscribers that implement
+// MessageNode receive the full message; others receive only the payload.
+//
+// Failed deliveries are retried according to each subscription's retry
+// policy. Deliveries that still fail, including recovered panics, are sent
+// to the node's dead-letter sink and returned joined together as
+// *DeliveryError values. Deliveries in progress hold up DeleteContext until
+// they finish. Paused and stopping nodes may still notify, so that messages
+// being replayed or drained and OnStop hooks can publish their results.
+//
+// Each delivered copy counts one more hop. A node with a hop limit set by
+// SetMaxHops refuses to notify messages that reached it.
+func (n *BaseNode) NotifyMessage(msg *Message) error {
+	return n.notify(msg, "")
+}
+
+// notify implements NotifyMessage, skipping the subscriber with the ID skip.
+func (n *BaseNode) notify(msg *Message, skip string) error {
+	n.mutex.RLock()
+	if n.state != StateRunning && n.state != StatePaused && n.state != StateStopping {
+		defer n.mutex.RUnlock()
+		return &StateError{NodeID: n.id, State: n.state}
+	}
+	if n.maxHops > 0 && msg.Hops >= n.maxHops {
+		n.mutex.RUnlock()
+		return fmt.Errorf("node %s: message %s after %d hops: %w", n.id, msg.ID, msg.Hops, ErrTTLExceeded)
+	}
+	subs := make([]*subscription, 0, len(n.subscriptions))
+	for _, sub := range n.subscriptions {
+		subs = append(subs, sub)
+	}
+	// Hold the node open for draining until every delivery is registered.
+	n.inflight.hold()
+	n.mutex.RUnlock()
 
-    var wg sync.WaitGroup
-    for _, node := range n.subscriptions {
-        wg.Add(1)
-        go func(n Node) {
-            defer wg.Done()
-            n.Process(event)
-        }(node)
-    }
-    wg.Wait()
-    return nil
+	var (
+		wg    sync.WaitGroup
+		errMu sync.Mutex
+		errs  []error
+	)
+	for _, sub := range subs {
+		if !sub.matches(msg.Payload) || (skip != "" && sub.node.GetID() == skip) {
+			continue
+		}
+		delivery := msg.Clone()
+		delivery.Source = n.id
+		delivery.Hops++
+		deliveryKey := n.inflight.add(delivery, sub.node.GetID())
+		wg.Add(1)
+		go func(sub *subscription) {
+			defer wg.Done()
+			defer n.inflight.done(deliveryKey)
+			if err := n.deliverTo(sub, delivery); err != nil {
+				errMu.Lock()
+				errs = append(errs, err)
+				errMu.Unlock()
+			}
+		}(sub)
+	}
+	n.inflight.release()
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
 // GetID returns the node's unique identifier.
 func (n *BaseNode) GetID() string {
-    return n.id
+	return n.id
 }
 
 // GetSubscription returns a subscribed node by ID, or nil if not found.
 func (n *BaseNode) GetSubscription(id string) Node {
-    n.mutex.RLock()
-    defer n.mutex.RUnlock()
-    return n.subscriptions[id]
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if sub, ok := n.subscriptions[id]; ok {
+		return sub.node
+	}
+	return nil
+}
+
+// GetSubscribers returns the nodes subscribed to this node, sorted by ID.
+func (n *BaseNode) GetSubscribers() []Node {
+	return subscriberNodes(n)
+}
+
+// GetSubscriptionStats returns the delivery counters of a subscription. ok
+// is false if the node has no such subscription.
+func (n *BaseNode) GetSubscriptionStats(id string) (stats SubscriptionStats, ok bool) {
+	n.mutex.RLock()
+	sub, found := n.subscriptions[id]
+	n.mutex.RUnlock()
+	if !found {
+		return SubscriptionStats{}, false
+	}
+	return sub.stats(), true
+}
+
+// GetBreakerState returns the circuit breaker state of a subscription. ok
+// is false if the node has no such subscription or it has no breaker.
+func (n *BaseNode) GetBreakerState(id string) (state BreakerState, ok bool) {
+	n.mutex.RLock()
+	sub, found := n.subscriptions[id]
+	n.mutex.RUnlock()
+	if !found || sub.breaker == nil {
+		return BreakerClosed, false
+	}
+	return sub.breaker.currentState(), true
 }
 
 // GetEventCount returns the number of events received by this node.
 func (n *BaseNode) GetEventCount() uint64 {
-    return atomic.LoadUint64(&n.eventCounter)
-}
\ No newline at end of file
+	return atomic.LoadUint64(&n.eventCounter)
+}
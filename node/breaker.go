@@ -0,0 +1,192 @@
+package node
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for deliveries skipped because the
+// subscriber's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every delivery through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen skips deliveries until the cooldown has elapsed.
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of trial deliveries through to
+	// probe whether the subscriber has recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerEvent describes a circuit breaker state change.
+type BreakerEvent struct {
+	PublisherID  string
+	SubscriberID string
+	From         BreakerState
+	To           BreakerState
+	// Err is the failure that opened the breaker, if any.
+	Err  error
+	Time time.Time
+}
+
+// CircuitBreakerConfig configures a per-subscription circuit breaker.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker. Zero means 5.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before allowing trial
+	// deliveries. Zero means 30 seconds.
+	Cooldown time.Duration
+	// HalfOpenMaxCalls is the number of concurrent trial deliveries allowed
+	// while half-open. Zero means 1.
+	HalfOpenMaxCalls int
+	// SuccessThreshold is the number of successful trial deliveries that
+	// closes the breaker again. Zero means 1.
+	SuccessThreshold int
+	// Fallback, if set, receives events while the breaker is open instead
+	// of the subscriber.
+	Fallback Node
+	// OnStateChange, if set, is called after every state change.
+	OnStateChange func(BreakerEvent)
+}
+
+// WithCircuitBreaker guards deliveries to the subscriber with a circuit
+// breaker. While the breaker is open, Notify skips the subscriber, routing
+// events to the configured fallback node if there is one.
+func WithCircuitBreaker(config CircuitBreakerConfig) SubscriptionOption {
+	return func(s *subscription) error {
+		if config.FailureThreshold == 0 {
+			config.FailureThreshold = 5
+		}
+		if config.Cooldown == 0 {
+			config.Cooldown = 30 * time.Second
+		}
+		if config.HalfOpenMaxCalls == 0 {
+			config.HalfOpenMaxCalls = 1
+		}
+		if config.SuccessThreshold == 0 {
+			config.SuccessThreshold = 1
+		}
+		s.breaker = &circuitBreaker{config: config, subscriberID: s.node.GetID()}
+		return nil
+	}
+}
+
+// circuitBreaker tracks delivery outcomes for a single subscription.
+type circuitBreaker struct {
+	config       CircuitBreakerConfig
+	publisherID  string
+	subscriberID string
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	inFlight  int
+	openedAt  time.Time
+}
+
+// allow reports whether a delivery may proceed, moving an open breaker to
+// half-open once its cooldown has elapsed. trial is true for deliveries let
+// through while half-open.
+func (b *circuitBreaker) allow() (allowed, trial bool) {
+	b.mu.Lock()
+	var event *BreakerEvent
+	allowed = true
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.Cooldown {
+			allowed = false
+			break
+		}
+		event = b.transition(BreakerHalfOpen, nil)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.inFlight >= b.config.HalfOpenMaxCalls {
+			allowed = false
+			break
+		}
+		b.inFlight++
+		trial = true
+	}
+	b.mu.Unlock()
+	b.emit(event)
+	return allowed, trial
+}
+
+// record updates the breaker with the outcome of an allowed delivery.
+// Outcomes of non-trial deliveries that finish after the breaker has left
+// the closed state are ignored.
+func (b *circuitBreaker) record(trial bool, err error) {
+	b.mu.Lock()
+	var event *BreakerEvent
+	switch b.state {
+	case BreakerClosed:
+		if err == nil {
+			b.failures = 0
+		} else if b.failures++; b.failures >= b.config.FailureThreshold {
+			event = b.transition(BreakerOpen, err)
+		}
+	case BreakerHalfOpen:
+		if !trial {
+			break
+		}
+		b.inFlight--
+		if err != nil {
+			event = b.transition(BreakerOpen, err)
+		} else if b.successes++; b.successes >= b.config.SuccessThreshold {
+			event = b.transition(BreakerClosed, nil)
+		}
+	}
+	b.mu.Unlock()
+	b.emit(event)
+}
+
+// currentState returns the breaker's current state.
+func (b *circuitBreaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// transition changes state and resets counters. It must be called with mu
+// held.
+func (b *circuitBreaker) transition(to BreakerState, cause error) *BreakerEvent {
+	event := &BreakerEvent{
+		PublisherID:  b.publisherID,
+		SubscriberID: b.subscriberID,
+		From:         b.state,
+		To:           to,
+		Err:          cause,
+		Time:         time.Now(),
+	}
+	b.state = to
+	b.failures, b.successes, b.inFlight = 0, 0, 0
+	if to == BreakerOpen {
+		b.openedAt = event.Time
+	}
+	return event
+}
+
+func (b *circuitBreaker) emit(event *BreakerEvent) {
+	if event != nil && b.config.OnStateChange != nil {
+		b.config.OnStateChange(*event)
+	}
+}
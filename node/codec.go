@@ -0,0 +1,133 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec converts values to and from the []byte wire format used by nodes.
+// JSON, gob and protobuf codecs are provided; other formats such as msgpack
+// can be plugged in by implementing this interface.
+type Codec interface {
+	// Name returns a short identifier for the codec, e.g. "json".
+	Name() string
+
+	// ContentType returns the MIME type of encoded payloads.
+	ContentType() string
+
+	// Marshal encodes v.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into the value pointed to by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON.
+type JSONCodec struct{}
+
+// Name returns "json".
+func (JSONCodec) Name() string { return "json" }
+
+// ContentType returns "application/json".
+func (JSONCodec) ContentType() string { return "application/json" }
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// GobCodec encodes values with encoding/gob.
+type GobCodec struct{}
+
+// Name returns "gob".
+func (GobCodec) Name() string { return "gob" }
+
+// ContentType returns "application/x-gob".
+func (GobCodec) ContentType() string { return "application/x-gob" }
+
+// Marshal encodes v with gob.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into v.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ProtoCodec encodes protocol buffer messages. Values must implement
+// proto.Message, or be pointers to a nil proto.Message pointer when decoding.
+type ProtoCodec struct{}
+
+// Name returns "protobuf".
+func (ProtoCodec) Name() string { return "protobuf" }
+
+// ContentType returns "application/x-protobuf".
+func (ProtoCodec) ContentType() string { return "application/x-protobuf" }
+
+// Marshal encodes v, which must implement proto.Message.
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v, allocating the target message if v points
+// to a nil message pointer.
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("%T does not implement proto.Message", v)
+	}
+	target := rv.Elem()
+	if target.IsNil() {
+		target.Set(reflect.New(target.Type().Elem()))
+	}
+	m, ok := target.Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T does not implement proto.Message", target.Interface())
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// DecodeError reports a payload that could not be decoded by a codec.
+type DecodeError struct {
+	Codec string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s decode: %v", e.Codec, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrMalformed.
+func (e *DecodeError) Is(target error) bool { return target == ErrMalformed }
+
+// EncodeError reports a value that could not be encoded by a codec.
+type EncodeError struct {
+	Codec string
+	Err   error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("%s encode: %v", e.Codec, e.Err)
+}
+
+func (e *EncodeError) Unwrap() error { return e.Err }
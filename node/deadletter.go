@@ -0,0 +1,336 @@
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrMalformed marks an event that a subscriber rejected as malformed.
+// Decode errors from typed nodes match it.
+var ErrMalformed = errors.New("malformed event")
+
+// DeadLetterReason explains why an event was dead-lettered.
+type DeadLetterReason string
+
+const (
+	// ReasonRetriesExhausted means every allowed delivery attempt failed.
+	ReasonRetriesExhausted DeadLetterReason = "retries_exhausted"
+	// ReasonRejected means the subscriber failed with a permanent error.
+	ReasonRejected DeadLetterReason = "rejected"
+	// ReasonMalformed means the subscriber rejected the event as malformed.
+	ReasonMalformed DeadLetterReason = "malformed"
+	// ReasonQuarantined means the subscriber is quarantined.
+	ReasonQuarantined DeadLetterReason = "quarantined"
+	// ReasonCircuitOpen means the subscriber's circuit breaker was open and
+	// no fallback accepted the event.
+	ReasonCircuitOpen DeadLetterReason = "circuit_open"
+	// ReasonTTLExceeded means the subscriber tried to publish the event
+	// beyond its hop limit, usually because of a subscription cycle.
+	ReasonTTLExceeded DeadLetterReason = "ttl_exceeded"
+)
+
+// DeadLetter records an event that could not be delivered to a subscriber.
+type DeadLetter struct {
+	Message      *Message
+	PublisherID  string
+	SubscriberID string
+	Reason       DeadLetterReason
+	Err          error
+	Attempts     int
+	Time         time.Time
+}
+
+// ErrorChain returns the messages of Err and every error it wraps, outermost
+// first.
+func (d DeadLetter) ErrorChain() []string {
+	var chain []string
+	for err := d.Err; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, err.Error())
+	}
+	return chain
+}
+
+type deadLetterRecord struct {
+	MessageID    string            `json:"message_id"`
+	Source       string            `json:"source"`
+	Timestamp    time.Time         `json:"timestamp"`
+	ContentType  string            `json:"content_type,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Payload      []byte            `json:"payload"`
+	Hops         int               `json:"hops,omitempty"`
+	PublisherID  string            `json:"publisher_id"`
+	SubscriberID string            `json:"subscriber_id"`
+	Reason       DeadLetterReason  `json:"reason"`
+	Errors       []string          `json:"errors"`
+	Attempts     int               `json:"attempts"`
+	Time         time.Time         `json:"time"`
+}
+
+// MarshalJSON encodes the dead letter, flattening its error chain to strings.
+func (d DeadLetter) MarshalJSON() ([]byte, error) {
+	rec := deadLetterRecord{
+		PublisherID:  d.PublisherID,
+		SubscriberID: d.SubscriberID,
+		Reason:       d.Reason,
+		Errors:       d.ErrorChain(),
+		Attempts:     d.Attempts,
+		Time:         d.Time,
+	}
+	if m := d.Message; m != nil {
+		rec.MessageID, rec.Source, rec.Timestamp = m.ID, m.Source, m.Timestamp
+		rec.ContentType, rec.Headers, rec.Payload = m.ContentType, m.Headers, m.Payload
+		rec.Hops = m.Hops
+	}
+	return json.Marshal(rec)
+}
+
+// UnmarshalJSON decodes a dead letter. The error chain is restored as a
+// single error carrying the outermost message.
+func (d *DeadLetter) UnmarshalJSON(data []byte) error {
+	var rec deadLetterRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	*d = DeadLetter{
+		Message: &Message{
+			ID:          rec.MessageID,
+			Source:      rec.Source,
+			Timestamp:   rec.Timestamp,
+			ContentType: rec.ContentType,
+			Headers:     rec.Headers,
+			Payload:     rec.Payload,
+			Hops:        rec.Hops,
+		},
+		PublisherID:  rec.PublisherID,
+		SubscriberID: rec.SubscriberID,
+		Reason:       rec.Reason,
+		Attempts:     rec.Attempts,
+		Time:         rec.Time,
+	}
+	if len(rec.Errors) > 0 {
+		d.Err = errors.New(rec.Errors[0])
+	}
+	return nil
+}
+
+// DeadLetterSink receives events that could not be delivered.
+type DeadLetterSink interface {
+	Put(dl DeadLetter) error
+}
+
+// NodeSink forwards dead letters to a node as JSON-encoded messages.
+type NodeSink struct {
+	node Node
+}
+
+// NewNodeSink creates a sink that delivers dead letters to node.
+func NewNodeSink(node Node) *NodeSink {
+	return &NodeSink{node: node}
+}
+
+// Put encodes the dead letter and hands it to the node.
+func (s *NodeSink) Put(dl DeadLetter) error {
+	payload, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	msg := NewMessage(dl.PublisherID, payload)
+	msg.ContentType = "application/json"
+	msg.SetHeader("dead-letter-reason", string(dl.Reason))
+	msg.SetHeader("dead-letter-subscriber", dl.SubscriberID)
+	_, err = deliver(s.node, msg)
+	return err
+}
+
+// RingSink keeps the most recent dead letters in memory, discarding the
+// oldest once capacity is reached.
+type RingSink struct {
+	mu      sync.Mutex
+	records []DeadLetter
+	start   int
+	size    int
+	dropped uint64
+}
+
+// NewRingSink creates an in-memory sink holding up to capacity dead letters.
+func NewRingSink(capacity int) *RingSink {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingSink{records: make([]DeadLetter, capacity)}
+}
+
+// Put stores the dead letter, overwriting the oldest one if the ring is full.
+func (s *RingSink) Put(dl DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size == len(s.records) {
+		s.records[s.start] = dl
+		s.start = (s.start + 1) % len(s.records)
+		s.dropped++
+		return nil
+	}
+	s.records[(s.start+s.size)%len(s.records)] = dl
+	s.size++
+	return nil
+}
+
+// Records returns the stored dead letters, oldest first.
+func (s *RingSink) Records() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+// Drain returns the stored dead letters, oldest first, and empties the ring.
+func (s *RingSink) Drain() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.snapshot()
+	s.start, s.size = 0, 0
+	for i := range s.records {
+		s.records[i] = DeadLetter{}
+	}
+	return records
+}
+
+// Dropped returns the number of dead letters overwritten because the ring
+// was full.
+func (s *RingSink) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *RingSink) snapshot() []DeadLetter {
+	records := make([]DeadLetter, s.size)
+	for i := range records {
+		records[i] = s.records[(s.start+i)%len(s.records)]
+	}
+	return records
+}
+
+// FileSink appends dead letters to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileSink opens path for appending, creating it if necessary.
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Put appends the dead letter to the file.
+func (s *FileSink) Put(dl DeadLetter) error {
+	line, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// ReadDeadLetters reads the dead letters written to path by a FileSink.
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var dl DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+			return records, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, dl)
+	}
+	return records, scanner.Err()
+}
+
+// deadLetterReason classifies a failed delivery.
+func deadLetterReason(err error, retry RetryPolicy) DeadLetterReason {
+	switch {
+	case errors.Is(err, ErrQuarantined):
+		return ReasonQuarantined
+	case errors.Is(err, ErrCircuitOpen):
+		return ReasonCircuitOpen
+	case errors.Is(err, ErrMalformed):
+		return ReasonMalformed
+	case errors.Is(err, ErrTTLExceeded):
+		return ReasonTTLExceeded
+	case retry.retryable(err):
+		return ReasonRetriesExhausted
+	default:
+		return ReasonRejected
+	}
+}
+
+// SetDeadLetterSink sets the sink receiving events that could not be
+// delivered to a subscriber. A nil sink drops them.
+func (n *BaseNode) SetDeadLetterSink(sink DeadLetterSink) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.deadLetters = sink
+}
+
+// Redrive delivers a dead-lettered event to its subscriber again, applying
+// the subscription's current settings. If delivery fails again the event is
+// dead-lettered anew and the failure is returned. Like NotifyMessage, it
+// fails with a *StateError unless the node is running, paused or stopping.
+func (n *BaseNode) Redrive(dl DeadLetter) error {
+	if dl.Message == nil {
+		return fmt.Errorf("redrive to %s: %w", dl.SubscriberID, Permanent(errors.New("dead letter has no message")))
+	}
+	n.mutex.RLock()
+	if n.state != StateRunning && n.state != StatePaused && n.state != StateStopping {
+		defer n.mutex.RUnlock()
+		return fmt.Errorf("redrive %s: %w", dl.Message.ID, &StateError{NodeID: n.id, State: n.state})
+	}
+	sub, ok := n.subscriptions[dl.SubscriberID]
+	if !ok {
+		n.mutex.RUnlock()
+		return fmt.Errorf("redrive %s: node %s has no subscriber %s", dl.Message.ID, n.id, dl.SubscriberID)
+	}
+	// Register the delivery so that DeleteContext waits for it.
+	key := n.inflight.add(dl.Message, dl.SubscriberID)
+	n.mutex.RUnlock()
+	defer n.inflight.done(key)
+	return n.deliverTo(sub, dl.Message)
+}
+
+// RedriveAll redrives each dead letter in order and returns the failures.
+func (n *BaseNode) RedriveAll(records []DeadLetter) error {
+	var errs []error
+	for _, dl := range records {
+		if err := n.Redrive(dl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,171 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DeliveryError reports an event that a subscriber failed to process during
+// Notify.
+type DeliveryError struct {
+	SubscriberID string
+	MessageID    string
+	Attempts     int
+	Err          error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("delivery of %s to %s failed after %d attempt(s): %v", e.MessageID, e.SubscriberID, e.Attempts, e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// deliverTo delivers a message to a single subscription and sends it to the
+// dead-letter sink if delivery ultimately fails.
+func (n *BaseNode) deliverTo(sub *subscription, msg *Message) error {
+	start := time.Now()
+	attempts, result, err := sub.deliver(msg, n.inflight.aborted())
+	atomic.StoreInt64(&sub.lastLatency, int64(time.Since(start)))
+	if err == nil {
+		switch result {
+		case outcomeSampled:
+			atomic.AddUint64(&sub.sampled, 1)
+		case outcomeFallback:
+			atomic.AddUint64(&sub.fellBack, 1)
+		default:
+			atomic.AddUint64(&sub.delivered, 1)
+		}
+		return nil
+	}
+	atomic.AddUint64(&sub.failed, 1)
+	derr := &DeliveryError{
+		SubscriberID: sub.node.GetID(),
+		MessageID:    msg.ID,
+		Attempts:     attempts,
+		Err:          err,
+	}
+
+	n.mutex.RLock()
+	sink := n.deadLetters
+	n.mutex.RUnlock()
+	if sink == nil {
+		return derr
+	}
+	dl := DeadLetter{
+		Message:      msg,
+		PublisherID:  n.id,
+		SubscriberID: derr.SubscriberID,
+		Reason:       deadLetterReason(err, sub.retry),
+		Err:          err,
+		Attempts:     attempts,
+		Time:         time.Now(),
+	}
+	if serr := sink.Put(dl); serr != nil {
+		return errors.Join(derr, fmt.Errorf("dead-letter %s: %w", msg.ID, serr))
+	}
+	return derr
+}
+
+// outcome is how a delivery attempt ended.
+type outcome int
+
+const (
+	// outcomeDelivered means the subscriber was called.
+	outcomeDelivered outcome = iota
+	// outcomeSampled means the subscription's rate limit dropped the event.
+	outcomeSampled
+	// outcomeFallback means an open circuit breaker sent the event to its
+	// fallback node instead.
+	outcomeFallback
+)
+
+// deliver hands a message to the subscriber, retrying according to the
+// subscription's retry policy. It returns the number of attempts made and
+// the outcome and error of the last attempt.
+//
+// While the subscription's circuit breaker is open the subscriber is skipped
+// and the message goes to the breaker's fallback node, if any. Attempts
+// beyond the subscription's rate limit wait, fail with ErrRateLimited or are
+// dropped, depending on the limit's mode. Retries stop early once stop is
+// closed, returning the error of the last attempt.
+func (s *subscription) deliver(msg *Message, stop <-chan struct{}) (int, outcome, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := s.attempt(msg)
+		if result != outcomeDelivered || err == nil || attempt >= s.retry.MaxAttempts || !s.retry.retryable(err) {
+			return attempt, result, err
+		}
+		backoff := time.NewTimer(s.retry.Backoff(attempt))
+		select {
+		case <-backoff.C:
+		case <-stop:
+			backoff.Stop()
+			return attempt, result, err
+		}
+	}
+}
+
+// attempt makes a single delivery attempt. Only attempts that reached the
+// subscriber, with outcomeDelivered, may be retried.
+func (s *subscription) attempt(msg *Message) (outcome, error) {
+	if s.limiter != nil {
+		switch err := s.limiter.acquire(); err {
+		case nil:
+		case errSampledOut:
+			return outcomeSampled, nil
+		default:
+			return outcomeDelivered, err
+		}
+	}
+
+	trial := false
+	if s.breaker != nil {
+		var allowed bool
+		if allowed, trial = s.breaker.allow(); !allowed {
+			return outcomeFallback, s.fallback(msg)
+		}
+	}
+	_, err := deliver(s.node, msg)
+	if s.breaker != nil {
+		s.breaker.record(trial, err)
+	}
+	return outcomeDelivered, err
+}
+
+// fallback hands a message skipped by an open circuit breaker to the
+// breaker's fallback node.
+func (s *subscription) fallback(msg *Message) error {
+	fallback := s.breaker.config.Fallback
+	if fallback == nil {
+		return ErrCircuitOpen
+	}
+	if _, err := deliver(fallback, msg); err != nil {
+		return fmt.Errorf("%w: fallback %s: %w", ErrCircuitOpen, fallback.GetID(), err)
+	}
+	return nil
+}
+
+// deliver hands a message to a node, preserving the envelope when the node
+// understands it. Panics raised by nodes that do not recover them themselves
+// are returned as a *PanicError; a *PanicError rethrown under PanicRethrow
+// keeps propagating.
+func deliver(node Node, msg *Message) (out *Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, rethrown := r.(*PanicError); rethrown {
+				panic(r)
+			}
+			out, err = nil, newPanicError(node.GetID(), r)
+		}
+	}()
+
+	if mn, ok := node.(MessageNode); ok {
+		return mn.ProcessMessage(msg)
+	}
+	payload, err := node.Process(msg.Payload)
+	if payload == nil {
+		return nil, err
+	}
+	return msg.Derive(payload), err
+}
@@ -0,0 +1,184 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InFlight describes a message a node was working on.
+type InFlight struct {
+	Message *Message
+	// SubscriberID is the subscriber a delivery was addressed to. It is
+	// empty for messages passed to Process.
+	SubscriberID string
+	Since        time.Time
+}
+
+// DrainReport summarizes how a node drained its work on shutdown.
+type DrainReport struct {
+	// Drained is the number of messages that finished while draining.
+	Drained int
+	// Abandoned lists the messages still in flight when the drain deadline
+	// passed, oldest first. They may still complete after Delete returns.
+	Abandoned []InFlight
+	Duration  time.Duration
+}
+
+// inFlight tracks the messages a node is processing or delivering.
+type inFlight struct {
+	mu       sync.Mutex
+	next     uint64
+	entries  map[uint64]InFlight
+	holds    int // uncounted holds keeping the node from going idle
+	draining bool
+	drained  int
+	idle     chan struct{} // closed when the last entry finishes while draining
+	abort    chan struct{} // closed when a drain gives up on its entries
+}
+
+// add registers a message and returns the key to pass to done.
+func (t *inFlight) add(msg *Message, subscriberID string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[uint64]InFlight)
+	}
+	t.next++
+	t.entries[t.next] = InFlight{Message: msg, SubscriberID: subscriberID, Since: time.Now()}
+	return t.next
+}
+
+// done removes a finished message.
+func (t *inFlight) done(key uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+	if !t.draining {
+		return
+	}
+	t.drained++
+	t.checkIdle()
+}
+
+// hold keeps the node from going idle, without tracking a message, until
+// release is called.
+func (t *inFlight) hold() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.holds++
+}
+
+// release ends a hold.
+func (t *inFlight) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.holds--
+	if t.draining {
+		t.checkIdle()
+	}
+}
+
+// checkIdle wakes a drain once nothing is in flight. t.mu must be held.
+func (t *inFlight) checkIdle() {
+	if len(t.entries) == 0 && t.holds == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// aborted returns a channel closed once a drain has given up waiting, so
+// that work such as delivery retries can stop early.
+func (t *inFlight) aborted() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.abort == nil {
+		t.abort = make(chan struct{})
+	}
+	return t.abort
+}
+
+// drain waits until no messages are in flight or ctx is done.
+func (t *inFlight) drain(ctx context.Context) DrainReport {
+	start := time.Now()
+	t.mu.Lock()
+	t.draining = true
+	var idle chan struct{}
+	if len(t.entries) > 0 || t.holds > 0 {
+		idle = make(chan struct{})
+		t.idle = idle
+	}
+	t.mu.Unlock()
+
+	timedOut := false
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			timedOut = true
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timedOut {
+		if t.abort == nil {
+			t.abort = make(chan struct{})
+		}
+		close(t.abort)
+	}
+	report := DrainReport{Drained: t.drained, Duration: time.Since(start)}
+	for _, entry := range t.entries {
+		report.Abandoned = append(report.Abandoned, entry)
+	}
+	sortInFlight(report.Abandoned)
+	t.idle = nil
+	return report
+}
+
+// sortInFlight orders messages oldest first.
+func sortInFlight(entries []InFlight) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Since.Before(entries[j].Since)
+	})
+}
+
+// DeleteContext stops the node gracefully. The node stops accepting new
+// events, detaches from its publishers and waits, until ctx is done, for
+// in-flight Process calls and outgoing deliveries to finish before running
+// its OnStop hooks. Messages still in flight when ctx is done, and events
+// buffered by a paused node, are listed in the report and an error is
+// returned; the node is stopped either way. Outgoing deliveries waiting to
+// retry give up once ctx is done.
+func (n *BaseNode) DeleteContext(ctx context.Context) (DrainReport, error) {
+	if err := n.transition(StateStopping); err != nil {
+		return DrainReport{}, err
+	}
+	n.detachFromPublishers()
+
+	report := n.inflight.drain(ctx)
+	cause := ctx.Err()
+	if held := n.takePaused(); len(held) > 0 {
+		report.Abandoned = append(report.Abandoned, held...)
+		sortInFlight(report.Abandoned)
+		if cause == nil {
+			cause = ErrPaused
+		}
+	}
+	var drainErr error
+	if len(report.Abandoned) > 0 {
+		drainErr = fmt.Errorf("node %s: drain: abandoned %d messages: %w", n.id, len(report.Abandoned), cause)
+	}
+
+	if err := runHooks(n.hooks(false), false); err != nil {
+		n.forceState(StateFailed)
+		return report, errors.Join(drainErr, fmt.Errorf("node %s: stop: %w", n.id, err))
+	}
+	if err := n.transition(StateStopped); err != nil {
+		return report, err
+	}
+	return report, drainErr
+}
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportDOT writes the subscription graph of nodes, and of every node
+// they reach through their subscriptions, in Graphviz DOT format. Nodes are
+// labeled and colored by lifecycle state; edges are labeled with their
+// event counts and error rates.
+func ExportDOT(w io.Writer, nodes ...Node) error {
+	g := buildGraph(nodes)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph constellation {")
+	fmt.Fprintln(bw, "  node [shape=box, style=\"rounded,filled\", fillcolor=white];")
+	for _, id := range g.ids {
+		state, ok := nodeState(g.nodes[id])
+		label := id
+		attrs := ""
+		if ok {
+			label += "\n" + state.String()
+			attrs = fmt.Sprintf(", fillcolor=%q", dotColors[state])
+		}
+		fmt.Fprintf(bw, "  %s [label=%s%s];\n", dotQuote(id), dotQuote(label), attrs)
+	}
+	for _, id := range g.ids {
+		for _, to := range g.edges[id] {
+			stats, _ := subscriptionStats(g.nodes[id], to)
+			attrs := fmt.Sprintf("label=%s", dotQuote(edgeLabel(stats)))
+			if stats.Failed > 0 {
+				attrs += ", color=red"
+			}
+			fmt.Fprintf(bw, "  %s -> %s [%s];\n", dotQuote(id), dotQuote(to), attrs)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// ExportMermaid writes the subscription graph of nodes, and of every node
+// they reach through their subscriptions, as a Mermaid flowchart. Nodes are
+// labeled and styled by lifecycle state; edges are labeled with their event
+// counts and error rates.
+func ExportMermaid(w io.Writer, nodes ...Node) error {
+	g := buildGraph(nodes)
+	alias := make(map[string]string, len(g.ids))
+	for i, id := range g.ids {
+		alias[id] = fmt.Sprintf("n%d", i)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart LR")
+	used := make(map[State]bool)
+	for _, id := range g.ids {
+		label := mermaidEscape(id)
+		class := ""
+		if state, ok := nodeState(g.nodes[id]); ok {
+			label += "<br/>" + state.String()
+			class = ":::" + state.String()
+			used[state] = true
+		}
+		fmt.Fprintf(bw, "  %s[\"%s\"]%s\n", alias[id], label, class)
+	}
+	for _, id := range g.ids {
+		for _, to := range g.edges[id] {
+			stats, _ := subscriptionStats(g.nodes[id], to)
+			fmt.Fprintf(bw, "  %s -->|\"%s\"| %s\n", alias[id], mermaidEscape(edgeLabel(stats)), alias[to])
+		}
+	}
+	for state := StateNew; state <= StateFailed; state++ {
+		if used[state] {
+			fmt.Fprintf(bw, "  classDef %s fill:%s\n", state, dotColors[state])
+		}
+	}
+	return bw.Flush()
+}
+
+// dotColors maps lifecycle states to fill colors.
+var dotColors = map[State]string{
+	StateNew:      "#eeeeee",
+	StateStarting: "#cfe2ff",
+	StateRunning:  "#d1e7dd",
+	StatePaused:   "#fff3cd",
+	StateStopping: "#e2d9f3",
+	StateStopped:  "#dddddd",
+	StateFailed:   "#f8d7da",
+}
+
+// nodeState returns the lifecycle state of nodes that report one.
+func nodeState(n Node) (State, bool) {
+	if s, ok := n.(interface{ GetState() State }); ok {
+		return s.GetState(), true
+	}
+	return StateNew, false
+}
+
+// subscriptionStats returns the counters of the subscription of to to the
+// publisher, if the publisher is built on BaseNode.
+func subscriptionStats(publisher Node, to string) (SubscriptionStats, bool) {
+	if b, ok := publisher.(baseNoder); ok {
+		return b.base().GetSubscriptionStats(to)
+	}
+	return SubscriptionStats{}, false
+}
+
+// edgeLabel describes a subscription's counters.
+func edgeLabel(stats SubscriptionStats) string {
+	label := fmt.Sprintf("%d events", stats.Delivered+stats.Failed)
+	if stats.Failed > 0 {
+		label += fmt.Sprintf(", %.1f%% errors", 100*stats.ErrorRate())
+	}
+	if stats.Sampled > 0 {
+		label += fmt.Sprintf(", %d sampled out", stats.Sampled)
+	}
+	if stats.Fallback > 0 {
+		label += fmt.Sprintf(", %d to fallback", stats.Fallback)
+	}
+	return label
+}
+
+// dotQuote quotes a DOT identifier or label.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// mermaidEscape escapes text inside a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
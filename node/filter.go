@@ -0,0 +1,352 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter reports whether an event should be delivered to a subscriber.
+type Filter func(event []byte) bool
+
+// CompileFilter compiles a declarative filter expression into a Filter.
+//
+// Expressions are evaluated against the event decoded as a JSON object.
+// Fields are referenced by name, with dots selecting nested fields, and can
+// be compared against numbers, quoted strings, true, false and null:
+//
+//	temp > 30 && site == "A"
+//	!(status == "ok") || reading.quality >= 0.9
+//
+// Supported operators are ==, !=, <, <=, >, >=, &&, || and !. A field that is
+// missing from the event evaluates to null. Events that are not JSON objects
+// never match.
+func CompileFilter(expr string) (Filter, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("filter: unexpected %q at offset %d", tok.text, tok.pos)
+	}
+
+	return func(event []byte) bool {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(event, &doc); err != nil {
+			return false
+		}
+		return truthy(root.eval(doc))
+	}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexFilter(expr string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expr) {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(expr) && rune(expr[i]) != c {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					i++
+				}
+				sb.WriteByte(expr[i])
+				i++
+			}
+			if i >= len(expr) {
+				return nil, fmt.Errorf("filter: unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		case c == '-' || c == '.' || unicode.IsDigit(c):
+			start := i
+			i++
+			for i < len(expr) && (unicode.IsDigit(rune(expr[i])) || expr[i] == '.' || expr[i] == 'e' || expr[i] == 'E' ||
+				((expr[i] == '-' || expr[i] == '+') && (expr[i-1] == 'e' || expr[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, expr[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(expr) && (unicode.IsLetter(rune(expr[i])) || unicode.IsDigit(rune(expr[i])) || expr[i] == '_' || expr[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, expr[start:i], start})
+		default:
+			start := i
+			var op string
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"} {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("filter: unexpected character %q at offset %d", c, start)
+			}
+			i += len(op)
+			tokens = append(tokens, token{tokOp, op, start})
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(expr)}), nil
+}
+
+type exprNode interface {
+	eval(doc map[string]interface{}) interface{}
+}
+
+type literalExpr struct{ value interface{} }
+
+func (e literalExpr) eval(map[string]interface{}) interface{} { return e.value }
+
+type fieldExpr struct{ path []string }
+
+func (e fieldExpr) eval(doc map[string]interface{}) interface{} {
+	var cur interface{} = doc
+	for _, part := range e.path {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = obj[part]
+	}
+	return cur
+}
+
+type notExpr struct{ operand exprNode }
+
+func (e notExpr) eval(doc map[string]interface{}) interface{} {
+	return !truthy(e.operand.eval(doc))
+}
+
+type logicalExpr struct {
+	op          string
+	left, right exprNode
+}
+
+func (e logicalExpr) eval(doc map[string]interface{}) interface{} {
+	if e.op == "&&" {
+		return truthy(e.left.eval(doc)) && truthy(e.right.eval(doc))
+	}
+	return truthy(e.left.eval(doc)) || truthy(e.right.eval(doc))
+}
+
+type compareExpr struct {
+	op          string
+	left, right exprNode
+}
+
+func (e compareExpr) eval(doc map[string]interface{}) interface{} {
+	l, r := e.left.eval(doc), e.right.eval(doc)
+	switch e.op {
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+
+	if lf, ok := l.(float64); ok {
+		if rf, ok := r.(float64); ok {
+			return compareOrdered(e.op, lf, rf)
+		}
+	}
+	if ls, ok := l.(string); ok {
+		if rs, ok := r.(string); ok {
+			return compareOrdered(e.op, ls, rs)
+		}
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+func equal(l, r interface{}) bool {
+	switch l.(type) {
+	case nil, float64, string, bool:
+		return l == r
+	}
+	return false
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		return t != ""
+	default:
+		return true
+	}
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() token { return p.tokens[p.pos] }
+
+func (p *filterParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokOp && tok.text == "||"; tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokOp && tok.text == "&&"; tok = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (exprNode, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return left, nil
+	}
+	switch tok.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareExpr{op: tok.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseOperand() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("filter: expected ) at offset %d, got %q", closing.pos, closing.text)
+		}
+		return inner, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return literalExpr{value: f}, nil
+	case tokString:
+		return literalExpr{value: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literalExpr{value: true}, nil
+		case "false":
+			return literalExpr{value: false}, nil
+		case "null":
+			return literalExpr{value: nil}, nil
+		}
+		path := strings.Split(tok.text, ".")
+		for _, part := range path {
+			if part == "" {
+				return nil, fmt.Errorf("filter: invalid field reference %q at offset %d", tok.text, tok.pos)
+			}
+		}
+		return fieldExpr{path: path}, nil
+	}
+	return nil, fmt.Errorf("filter: unexpected %q at offset %d", tok.text, tok.pos)
+}
@@ -0,0 +1,146 @@
+package node
+
+import (
+	"errors"
+	"strings"
+)
+
+// HeaderRoute lists the IDs of the nodes that forwarded a message, comma
+// separated, when the nodes prevent forwarding loops.
+const HeaderRoute = "constellation-route"
+
+// SplitFunc splits one output payload into several payloads that are
+// published in order.
+type SplitFunc func(payload []byte) [][]byte
+
+// ForwardConfig configures automatic publishing of process output.
+type ForwardConfig struct {
+	// SkipEmpty drops outputs with an empty payload instead of publishing
+	// them.
+	SkipEmpty bool
+	// Split, if set, fans each output out into several messages derived
+	// from it.
+	Split SplitFunc
+	// NoEcho skips the subscriber the input came from, so that two nodes
+	// subscribed to each other do not bounce an event back and forth.
+	NoEcho bool
+	// PreventLoops records the forwarding nodes in the HeaderRoute header
+	// and drops the outputs of messages this node already forwarded once.
+	PreventLoops bool
+}
+
+// SetForwarding makes the node publish the output of every message it
+// processes successfully to its subscribers, as if the process function
+// called NotifyMessage with it. Nil outputs are not published, and the
+// messages of a batch built with NewBatch are published one by one. Delivery
+// errors are returned from Process and ProcessMessage along with the output.
+func (n *BaseNode) SetForwarding(cfg ForwardConfig) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.forward = &cfg
+}
+
+// DisableForwarding stops automatic publishing of process output.
+func (n *BaseNode) DisableForwarding() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.forward = nil
+}
+
+// GetForwarding returns the node's forwarding configuration and whether
+// forwarding is enabled.
+func (n *BaseNode) GetForwarding() (ForwardConfig, bool) {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if n.forward == nil {
+		return ForwardConfig{}, false
+	}
+	return *n.forward, true
+}
+
+// NewBatch groups several output messages into one, for process functions
+// that produce more than one output for an input. Forwarding publishes the
+// messages of a batch in order instead of the batch itself. NewBatch returns
+// nil for no messages and the message itself for one.
+func NewBatch(msgs ...*Message) *Message {
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return msgs[0]
+	}
+	last := msgs[len(msgs)-1]
+	return &Message{ID: newMessageID(), Source: last.Source, Timestamp: last.Timestamp, batch: msgs}
+}
+
+// Batch returns the messages grouped by NewBatch, or m alone if it is not a
+// batch.
+func (m *Message) Batch() []*Message {
+	if m == nil {
+		return nil
+	}
+	if m.batch != nil {
+		return append([]*Message(nil), m.batch...)
+	}
+	return []*Message{m}
+}
+
+// forwardOutput publishes the output of in according to cfg.
+func (n *BaseNode) forwardOutput(cfg *ForwardConfig, in, out *Message) error {
+	if out == nil {
+		return nil
+	}
+	route := in.Header(HeaderRoute)
+	if cfg.PreventLoops && onRoute(route, n.id) {
+		return nil
+	}
+	if route != "" {
+		route += ","
+	}
+	skip := ""
+	if cfg.NoEcho {
+		skip = in.Source
+	}
+
+	var outputs []*Message
+	for _, msg := range out.Batch() {
+		if cfg.PreventLoops || msg.Hops < in.Hops {
+			msg = msg.Clone()
+		}
+		if cfg.PreventLoops {
+			msg.SetHeader(HeaderRoute, route+n.id)
+		}
+		// Output carries at least the hops of its input, so that hop
+		// limits hold whatever message the process function returned.
+		if msg.Hops < in.Hops {
+			msg.Hops = in.Hops
+		}
+		if cfg.Split == nil {
+			outputs = append(outputs, msg)
+			continue
+		}
+		for _, payload := range cfg.Split(msg.Payload) {
+			outputs = append(outputs, msg.Derive(payload))
+		}
+	}
+	var errs []error
+	for _, msg := range outputs {
+		if cfg.SkipEmpty && len(msg.Payload) == 0 {
+			continue
+		}
+		if err := n.notify(msg, skip); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// onRoute reports whether id is listed in a HeaderRoute value.
+func onRoute(route, id string) bool {
+	for _, hop := range strings.Split(route, ",") {
+		if hop == id {
+			return true
+		}
+	}
+	return false
+}
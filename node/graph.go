@@ -0,0 +1,305 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrCycle is returned by Subscribe on a node with cycle checking enabled
+// when the subscription would let events flow back to the publisher.
+var ErrCycle = errors.New("subscription would create a cycle")
+
+// ErrTTLExceeded is returned by Notify when a message has already been
+// delivered through the publisher's maximum number of hops.
+var ErrTTLExceeded = errors.New("message hop limit exceeded")
+
+// DefaultFanOutThreshold is the subscriber count from which AnalyzeGraph
+// reports a node as a fan-out hotspot when GraphOptions leaves
+// FanOutThreshold unset.
+const DefaultFanOutThreshold = 10
+
+// GraphOptions configures AnalyzeGraph.
+type GraphOptions struct {
+	// Roots are the IDs of the nodes events enter the graph through. Empty
+	// means every node without publishers.
+	Roots []string
+	// FanOutThreshold is the subscriber count from which a node is a
+	// hotspot. Zero means DefaultFanOutThreshold.
+	FanOutThreshold int
+}
+
+// Hotspot is a node with many subscribers.
+type Hotspot struct {
+	ID          string
+	Subscribers int
+}
+
+// GraphReport describes the subscription graph of a set of nodes.
+type GraphReport struct {
+	// Nodes lists every node in the graph, including subscribers reached
+	// from the analyzed nodes, sorted by ID.
+	Nodes []string
+	// Cycles lists one cycle per group of nodes that can reach each other,
+	// as the IDs along the cycle; the last node subscribes to the first.
+	Cycles [][]string
+	// SelfSubscriptions lists nodes subscribed to themselves.
+	SelfSubscriptions []string
+	// Unreachable lists nodes no event entering at a root can reach.
+	Unreachable []string
+	// Isolated lists nodes with neither publishers nor subscribers.
+	Isolated []string
+	// Hotspots lists nodes whose subscriber count reaches the fan-out
+	// threshold, busiest first.
+	Hotspots []Hotspot
+}
+
+// HasCycles reports whether events can flow back to a node they passed
+// through, either through a cycle or a self-subscription.
+func (r GraphReport) HasCycles() bool {
+	return len(r.Cycles) > 0 || len(r.SelfSubscriptions) > 0
+}
+
+// AnalyzeGraph analyzes the subscriptions between nodes. Subscribers of
+// the given nodes are followed and included even if they are not listed.
+// Only nodes built on BaseNode expose their subscriptions; other nodes
+// appear as leaves.
+func AnalyzeGraph(nodes []Node, opts GraphOptions) GraphReport {
+	g := buildGraph(nodes)
+	threshold := opts.FanOutThreshold
+	if threshold <= 0 {
+		threshold = DefaultFanOutThreshold
+	}
+
+	var report GraphReport
+	report.Nodes = g.ids
+	indegree := make(map[string]int, len(g.ids))
+	for _, id := range g.ids {
+		for _, to := range g.edges[id] {
+			if to == id {
+				report.SelfSubscriptions = append(report.SelfSubscriptions, id)
+				continue
+			}
+			indegree[to]++
+		}
+	}
+	for _, id := range g.ids {
+		if len(g.edges[id]) == 0 && indegree[id] == 0 {
+			report.Isolated = append(report.Isolated, id)
+		}
+		if len(g.edges[id]) >= threshold {
+			report.Hotspots = append(report.Hotspots, Hotspot{ID: id, Subscribers: len(g.edges[id])})
+		}
+	}
+	sort.SliceStable(report.Hotspots, func(i, j int) bool {
+		return report.Hotspots[i].Subscribers > report.Hotspots[j].Subscribers
+	})
+
+	for _, scc := range g.components() {
+		if len(scc) > 1 {
+			report.Cycles = append(report.Cycles, g.cycleThrough(scc))
+		}
+	}
+
+	roots := opts.Roots
+	if len(roots) == 0 {
+		for _, id := range g.ids {
+			if indegree[id] == 0 {
+				roots = append(roots, id)
+			}
+		}
+	}
+	reached := g.reachable(roots)
+	for _, id := range g.ids {
+		if !reached[id] {
+			report.Unreachable = append(report.Unreachable, id)
+		}
+	}
+	return report
+}
+
+// Analyze analyzes the subscriptions between the runtime's nodes.
+func (r *Runtime) Analyze(opts GraphOptions) GraphReport {
+	return AnalyzeGraph(r.Nodes(), opts)
+}
+
+// graph is a snapshot of the subscriptions between nodes, by ID.
+type graph struct {
+	ids   []string // sorted
+	nodes map[string]Node
+	edges map[string][]string
+}
+
+// buildGraph snapshots the subscriptions reachable from nodes.
+func buildGraph(nodes []Node) *graph {
+	g := &graph{nodes: make(map[string]Node), edges: make(map[string][]string)}
+	queue := append([]Node(nil), nodes...)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if _, seen := g.nodes[n.GetID()]; seen {
+			continue
+		}
+		g.nodes[n.GetID()] = n
+		g.ids = append(g.ids, n.GetID())
+		for _, sub := range subscriberNodes(n) {
+			g.edges[n.GetID()] = append(g.edges[n.GetID()], sub.GetID())
+			queue = append(queue, sub)
+		}
+	}
+	sort.Strings(g.ids)
+	return g
+}
+
+// subscriberNodes returns the subscribers of a node built on BaseNode,
+// sorted by ID.
+func subscriberNodes(n Node) []Node {
+	b, ok := n.(baseNoder)
+	if !ok {
+		return nil
+	}
+	subs := b.base().sortedSubscriptions()
+	nodes := make([]Node, len(subs))
+	for i, sub := range subs {
+		nodes[i] = sub.node
+	}
+	return nodes
+}
+
+// components returns the strongly connected components of the graph using
+// Tarjan's algorithm, each sorted by ID.
+func (g *graph) components() [][]string {
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		sccs    [][]string
+		visit   func(id string)
+	)
+	visit = func(id string) {
+		index[id] = len(index)
+		lowlink[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		for _, to := range g.edges[id] {
+			if _, visited := index[to]; !visited {
+				visit(to)
+				lowlink[id] = min(lowlink[id], lowlink[to])
+			} else if onStack[to] {
+				lowlink[id] = min(lowlink[id], index[to])
+			}
+		}
+		if lowlink[id] != index[id] {
+			return
+		}
+		var scc []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == id {
+				break
+			}
+		}
+		sort.Strings(scc)
+		sccs = append(sccs, scc)
+	}
+	for _, id := range g.ids {
+		if _, visited := index[id]; !visited {
+			visit(id)
+		}
+	}
+	sort.Slice(sccs, func(i, j int) bool { return sccs[i][0] < sccs[j][0] })
+	return sccs
+}
+
+// cycleThrough returns a shortest cycle through the first node of a
+// strongly connected component, staying within the component.
+func (g *graph) cycleThrough(scc []string) []string {
+	in := make(map[string]bool, len(scc))
+	for _, id := range scc {
+		in[id] = true
+	}
+	start := scc[0]
+	parent := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range g.edges[id] {
+			if to == start {
+				var cycle []string
+				for at := id; at != ""; at = parent[at] {
+					cycle = append([]string{at}, cycle...)
+				}
+				return cycle
+			}
+			if _, seen := parent[to]; !seen && in[to] {
+				parent[to] = id
+				queue = append(queue, to)
+			}
+		}
+	}
+	return scc
+}
+
+// reachable returns the nodes reachable from roots, including the roots.
+func (g *graph) reachable(roots []string) map[string]bool {
+	reached := make(map[string]bool)
+	queue := append([]string(nil), roots...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if reached[id] {
+			continue
+		}
+		reached[id] = true
+		queue = append(queue, g.edges[id]...)
+	}
+	return reached
+}
+
+// SetCycleCheck makes Subscribe refuse, with ErrCycle, subscriptions that
+// would let events published by this node flow back to it, including the
+// node subscribing to itself. The check follows the subscriptions of nodes
+// built on BaseNode and is not atomic with concurrent Subscribe calls on
+// other nodes.
+func (n *BaseNode) SetCycleCheck(enabled bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.cycleCheck = enabled
+}
+
+// SetMaxHops limits how many subscriptions a message may be delivered
+// through before it reaches this node's Notify; beyond that Notify fails
+// with ErrTTLExceeded. Zero means no limit. Hops are counted on messages
+// and NotifyMessage uses the count of the message it is given: output
+// forwarded by SetForwarding and messages derived from the input with
+// msg.Derive keep the input's count, while Notify starts a new message at
+// zero hops.
+func (n *BaseNode) SetMaxHops(max int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.maxHops = max
+}
+
+// checkCycle returns ErrCycle if subscribing node to n would close a cycle.
+func (n *BaseNode) checkCycle(node Node) error {
+	seen := make(map[string]bool)
+	queue := []Node{node}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if b, ok := next.(baseNoder); ok && b.base() == n {
+			return fmt.Errorf("subscribe %s to %s: %w", node.GetID(), n.id, ErrCycle)
+		}
+		if seen[next.GetID()] {
+			continue
+		}
+		seen[next.GetID()] = true
+		queue = append(queue, subscriberNodes(next)...)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package node
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+// KVStore is the key-value store behind a node's KV handle. Keys are ordered
+// bytewise for range scans. Implementations must be safe for concurrent
+// use; MemoryStore and FileStore are provided.
+type KVStore interface {
+	// Get returns the value of key and whether it exists.
+	Get(key string) ([]byte, bool, error)
+	// Put sets the value of key.
+	Put(key string, value []byte) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+	// Range calls fn for each key in [start, end) in order until fn
+	// returns false. An empty end means no upper bound. MemoryStore and
+	// FileStore let fn modify the store.
+	Range(start, end string, fn func(key string, value []byte) bool) error
+}
+
+// KVFunc processes a message with access to the node's key-value store.
+type KVFunc func(kv *KV, msg *Message) (*Message, error)
+
+// KV is a view of a KVStore scoped to a node and, optionally, to
+// narrower scopes such as a partition key. Keys read and written through a
+// handle are relative to its scope, so nodes sharing a store do not see
+// each other's keys.
+type KV struct {
+	store  KVStore
+	prefix string
+}
+
+// NewKV returns an unscoped view of store.
+func NewKV(store KVStore) *KV {
+	return &KV{store: store}
+}
+
+// Scope returns a view of the keys under name in s. Scope names must not
+// contain NUL bytes.
+func (s *KV) Scope(name string) *KV {
+	return &KV{store: s.store, prefix: s.prefix + name + "\x00"}
+}
+
+// Store returns the underlying store.
+func (s *KV) Store() KVStore {
+	return s.store
+}
+
+// Get returns the value of key and whether it exists.
+func (s *KV) Get(key string) ([]byte, bool, error) {
+	return s.store.Get(s.prefix + key)
+}
+
+// Put sets the value of key.
+func (s *KV) Put(key string, value []byte) error {
+	return s.store.Put(s.prefix+key, value)
+}
+
+// Delete removes key.
+func (s *KV) Delete(key string) error {
+	return s.store.Delete(s.prefix + key)
+}
+
+// Range calls fn for each key in the scope within [start, end), in order,
+// until fn returns false. An empty end means the end of the scope. Keys of
+// narrower scopes are included, with their scope names and NUL separators.
+func (s *KV) Range(start, end string, fn func(key string, value []byte) bool) error {
+	upper := s.prefix + end
+	if end == "" {
+		upper = prefixEnd(s.prefix)
+	}
+	return s.store.Range(s.prefix+start, upper, func(key string, value []byte) bool {
+		return fn(key[len(s.prefix):], value)
+	})
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, or "" if there is none.
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
+
+// kvRecord is the JSON form of a key in a snapshot.
+type kvRecord struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Snapshot writes every key in the scope to w as JSON lines.
+func (s *KV) Snapshot(w io.Writer) error {
+	var records []kvRecord
+	err := s.Range("", "", func(key string, value []byte) bool {
+		records = append(records, kvRecord{key, value})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore puts the keys of a snapshot written by Snapshot into the scope.
+// Existing keys not in the snapshot are kept.
+func (s *KV) Restore(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var rec kvRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := s.Put(rec.Key, rec.Value); err != nil {
+			return err
+		}
+	}
+}
+
+// MemoryStore is an in-memory KVStore. Its contents are lost when the
+// process exits.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+// Get returns a copy of the value of key.
+func (m *MemoryStore) Get(key string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.data[key]
+	return append([]byte(nil), v...), ok, nil
+}
+
+// Put stores a copy of value.
+func (m *MemoryStore) Put(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes key.
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+// Range calls fn with a consistent snapshot of the keys in [start, end).
+// fn may modify the store.
+func (m *MemoryStore) Range(start, end string, fn func(key string, value []byte) bool) error {
+	m.mu.RLock()
+	entries := rangeEntries(m.data, start, end)
+	m.mu.RUnlock()
+	for _, e := range entries {
+		if !fn(e.Key, e.Value) {
+			break
+		}
+	}
+	return nil
+}
+
+// rangeEntries returns the entries of data in [start, end), sorted by key.
+func rangeEntries(data map[string][]byte, start, end string) []kvRecord {
+	var entries []kvRecord
+	for k, v := range data {
+		if k >= start && (end == "" || k < end) {
+			entries = append(entries, kvRecord{k, append([]byte(nil), v...)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// SetKVStore sets the store backing the node's KV handle. Nodes use a
+// private MemoryStore until one is set; stores can be shared between
+// nodes.
+func (n *BaseNode) SetKVStore(store KVStore) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.store = NewKV(store).Scope(n.id)
+}
+
+// KV returns the node's key-value handle, scoped to the node's ID.
+func (n *BaseNode) KV() *KV {
+	n.mutex.RLock()
+	kv := n.store
+	n.mutex.RUnlock()
+	if kv != nil {
+		return kv
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.store == nil {
+		n.store = NewKV(NewMemoryStore()).Scope(n.id)
+	}
+	return n.store
+}
+
+// SetKVFunc allows setting a custom process function that receives the
+// node's key-value handle along with each message.
+func (n *BaseNode) SetKVFunc(fn KVFunc) {
+	n.SetMessageFunc(func(msg *Message) (*Message, error) {
+		return fn(n.KV(), msg)
+	})
+}
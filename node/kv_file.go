@@ -0,0 +1,304 @@
+package node
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileStore is a KVStore persisted in an append-only log file. Every
+// change is appended to the log before it takes effect, and the log is
+// replayed when the store is opened, so data survives restarts. Replay
+// stops at the first incomplete or corrupt record, such as one torn by a
+// crash, and the log is truncated there. All values are also kept in
+// memory.
+//
+// The log grows with every change; Compact rewrites it with only the live
+// keys, and the store compacts itself once the log holds several times more
+// records than keys.
+type FileStore struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	data    map[string][]byte
+	records int // records in the log
+	closed  bool
+}
+
+// Log record operations.
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+// compactMinRecords is the log size below which FileStore never compacts
+// on its own.
+const compactMinRecords = 1024
+
+// errClosed is returned by a FileStore after Close.
+var errClosed = errors.New("kv store closed")
+
+// OpenFileStore opens the store logged at path, creating it if necessary.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, data: make(map[string][]byte)}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	good, err := s.replay(f)
+	if err == nil {
+		// Drop a torn record at the end of the log.
+		err = f.Truncate(good)
+	}
+	if err == nil {
+		_, err = f.Seek(good, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open state store %s: %w", path, err)
+	}
+	s.file, s.w = f, bufio.NewWriter(f)
+	return s, nil
+}
+
+// replay applies the records of the log and returns the offset after the
+// last complete one.
+func (s *FileStore) replay(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+	var good int64
+	for {
+		op, key, value, n, err := readRecord(r)
+		if err == io.EOF || errors.Is(err, errTorn) {
+			return good, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		s.apply(op, key, value)
+		s.records++
+		good += n
+	}
+}
+
+// Get returns a copy of the value of key.
+func (s *FileStore) Get(key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, false, errClosed
+	}
+	v, ok := s.data[key]
+	return append([]byte(nil), v...), ok, nil
+}
+
+// Put logs and stores a copy of value.
+func (s *FileStore) Put(key string, value []byte) error {
+	return s.write(opPut, key, value)
+}
+
+// Delete logs the removal of key and removes it.
+func (s *FileStore) Delete(key string) error {
+	return s.write(opDelete, key, nil)
+}
+
+// Range calls fn with a consistent snapshot of the keys in [start, end).
+// fn may modify the store.
+func (s *FileStore) Range(start, end string, fn func(key string, value []byte) bool) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return errClosed
+	}
+	entries := rangeEntries(s.data, start, end)
+	s.mu.RUnlock()
+	for _, e := range entries {
+		if !fn(e.Key, e.Value) {
+			break
+		}
+	}
+	return nil
+}
+
+// Len returns the number of keys in the store.
+func (s *FileStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// Sync flushes the log to stable storage.
+func (s *FileStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Compact rewrites the log with only the live keys.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+	return s.compact()
+}
+
+// Close flushes and closes the log.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.w.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *FileStore) write(op byte, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+	if _, err := s.w.Write(encodeRecord(op, key, value)); err != nil {
+		return err
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	s.apply(op, key, value)
+	s.records++
+	if s.records > compactMinRecords && s.records > 4*len(s.data) {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *FileStore) apply(op byte, key string, value []byte) {
+	if op == opDelete {
+		delete(s.data, key)
+		return
+	}
+	s.data[key] = append([]byte{}, value...)
+}
+
+// compact writes the live keys to a new log and replaces the old one with
+// it. s.mu must be held.
+func (s *FileStore) compact() error {
+	tmp := s.path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range rangeEntries(s.data, "", "") {
+		if _, err = w.Write(encodeRecord(opPut, e.Key, e.Value)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("compact kv store %s: %w", s.path, err)
+	}
+	s.file.Close()
+	s.file, s.w = f, bufio.NewWriter(f)
+	s.records = len(s.data)
+	return nil
+}
+
+// errTorn marks an incomplete or corrupt record.
+var errTorn = errors.New("torn record")
+
+// encodeRecord encodes a log record: a CRC-32 of the rest of the record,
+// the operation, the key and value lengths as uvarints, the key and the
+// value.
+func encodeRecord(op byte, key string, value []byte) []byte {
+	buf := make([]byte, 4, 4+1+2*binary.MaxVarintLen64+len(key)+len(value))
+	buf = append(buf, op)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+	binary.LittleEndian.PutUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// readRecord reads one log record and returns its size.
+func readRecord(r *bufio.Reader) (op byte, key string, value []byte, n int64, err error) {
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err == io.EOF {
+		return 0, "", nil, 0, io.EOF
+	} else if err != nil {
+		return 0, "", nil, 0, errTorn
+	}
+	body := &countingReader{r: r}
+	op, err = body.ReadByte()
+	if err != nil {
+		return 0, "", nil, 0, errTorn
+	}
+	klen, err := binary.ReadUvarint(body)
+	if err != nil {
+		return 0, "", nil, 0, errTorn
+	}
+	vlen, err := binary.ReadUvarint(body)
+	if err != nil {
+		return 0, "", nil, 0, errTorn
+	}
+	if klen+vlen > 1<<31 {
+		return 0, "", nil, 0, errTorn
+	}
+	data := make([]byte, klen+vlen)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return 0, "", nil, 0, errTorn
+	}
+	if crc32.ChecksumIEEE(body.read) != binary.LittleEndian.Uint32(sum[:]) || (op != opPut && op != opDelete) {
+		return 0, "", nil, 0, errTorn
+	}
+	return op, string(data[:klen]), data[klen:], int64(4 + len(body.read)), nil
+}
+
+// countingReader records the bytes read through it.
+type countingReader struct {
+	r    *bufio.Reader
+	read []byte
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.read = append(c.read, b)
+	}
+	return b, err
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read = append(c.read, p[:n]...)
+	return n, err
+}
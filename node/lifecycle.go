@@ -0,0 +1,215 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotRunning is returned by Process and Notify on a node that is not in
+// the running state.
+var ErrNotRunning = errors.New("node not running")
+
+// ErrInvalidTransition is returned when a lifecycle transition is not
+// allowed from the node's current state.
+var ErrInvalidTransition = errors.New("invalid lifecycle transition")
+
+// State is a node's lifecycle state.
+type State int
+
+const (
+	// StateNew is the state of a node that has not been created yet.
+	StateNew State = iota
+	// StateStarting is the state while OnStart hooks run.
+	StateStarting
+	// StateRunning is the only state in which a node processes and
+	// publishes events.
+	StateRunning
+	// StatePaused is the state of a node that has been paused.
+	StatePaused
+	// StateStopping is the state while OnStop hooks run.
+	StateStopping
+	// StateStopped is the final state of a deleted node.
+	StateStopped
+	// StateFailed is the state of a node whose hooks failed.
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StatePaused:
+		return "paused"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// transitions lists the states reachable from each state.
+var transitions = map[State][]State{
+	StateNew:      {StateStarting, StateStopping},
+	StateStarting: {StateRunning, StateFailed},
+	StateRunning:  {StatePaused, StateStopping, StateFailed},
+	StatePaused:   {StateRunning, StateStopping, StateFailed},
+	StateStopping: {StateStopped, StateFailed},
+	StateFailed:   {StateStopping},
+}
+
+// canTransition reports whether a node may move from one state to another.
+func canTransition(from, to State) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError reports a lifecycle transition that is not allowed.
+type TransitionError struct {
+	NodeID string
+	From   State
+	To     State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("node %s: cannot move from %s to %s", e.NodeID, e.From, e.To)
+}
+
+// Is reports whether target is ErrInvalidTransition.
+func (e *TransitionError) Is(target error) bool { return target == ErrInvalidTransition }
+
+// StateError reports an operation rejected because of the node's state.
+type StateError struct {
+	NodeID string
+	State  State
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("node %s is %s", e.NodeID, e.State)
+}
+
+// Is reports whether target is ErrNotRunning.
+func (e *StateError) Is(target error) bool { return target == ErrNotRunning }
+
+// baseNoder is implemented by BaseNode and every type embedding it, giving
+// the package access to the underlying BaseNode of a subscriber.
+type baseNoder interface {
+	base() *BaseNode
+}
+
+func (n *BaseNode) base() *BaseNode { return n }
+
+// GetState returns the node's lifecycle state.
+func (n *BaseNode) GetState() State {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.state
+}
+
+// OnStart registers a hook run by Create before the node starts running.
+// Hooks run in registration order; the first error fails the node.
+func (n *BaseNode) OnStart(hook func() error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.startHooks = append(n.startHooks, hook)
+}
+
+// OnStop registers a hook run by Delete while the node is stopping. Hooks
+// run in reverse registration order; all hooks run even if one fails.
+func (n *BaseNode) OnStop(hook func() error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.stopHooks = append(n.stopHooks, hook)
+}
+
+// hooks returns a copy of the start or stop hooks in the order they run.
+func (n *BaseNode) hooks(start bool) []func() error {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if start {
+		return append([]func() error(nil), n.startHooks...)
+	}
+	hooks := make([]func() error, len(n.stopHooks))
+	for i, hook := range n.stopHooks {
+		hooks[len(hooks)-1-i] = hook
+	}
+	return hooks
+}
+
+// runHooks runs hooks in order and returns their joined errors. With
+// stopOnError it returns at the first failure.
+func runHooks(hooks []func() error, stopOnError bool) error {
+	var errs []error
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			if stopOnError {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// transition moves the node to state to if the lifecycle allows it.
+func (n *BaseNode) transition(to State) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if !canTransition(n.state, to) {
+		return &TransitionError{NodeID: n.id, From: n.state, To: to}
+	}
+	n.state = to
+	return nil
+}
+
+// forceState moves the node to state unconditionally.
+func (n *BaseNode) forceState(state State) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.state = state
+}
+
+// attachPublisher records that the node is subscribed to publisher.
+func (n *BaseNode) attachPublisher(publisher *BaseNode) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.publishers[publisher.id] = publisher
+}
+
+// detachPublisher forgets that the node is subscribed to publisher.
+func (n *BaseNode) detachPublisher(publisher *BaseNode) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.publishers[publisher.id] == publisher {
+		delete(n.publishers, publisher.id)
+	}
+}
+
+// detachFromPublishers unsubscribes the node from every node it is
+// subscribed to.
+func (n *BaseNode) detachFromPublishers() {
+	n.mutex.Lock()
+	publishers := n.publishers
+	n.publishers = make(map[string]*BaseNode)
+	n.mutex.Unlock()
+
+	for _, publisher := range publishers {
+		publisher.mutex.Lock()
+		if sub, ok := publisher.subscriptions[n.id]; ok {
+			if b, ok := sub.node.(baseNoder); ok && b.base() == n {
+				delete(publisher.subscriptions, n.id)
+			}
+		}
+		publisher.mutex.Unlock()
+	}
+}
@@ -0,0 +1,133 @@
+package node
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Well-known message header keys.
+const (
+	// HeaderTraceParent carries W3C trace context between nodes.
+	HeaderTraceParent = "traceparent"
+	// HeaderTraceState carries vendor-specific W3C trace state.
+	HeaderTraceState = "tracestate"
+)
+
+// Message is an event envelope carrying a payload together with metadata
+// about where and when it was produced.
+type Message struct {
+	// ID uniquely identifies the message within the process.
+	ID string
+	// Source is the ID of the node that published the message.
+	Source string
+	// Timestamp is the time the message was created.
+	Timestamp time.Time
+	// ContentType describes the payload encoding, e.g. "application/json".
+	ContentType string
+	// Headers holds arbitrary metadata such as trace context.
+	Headers map[string]string
+	// Payload is the message body.
+	Payload []byte
+	// Hops is the number of subscriptions the message has been delivered
+	// through. Derived messages keep the count of their input.
+	Hops int
+
+	batch []*Message // messages grouped by NewBatch
+}
+
+// ProcessFunc processes a raw payload and returns the output payload.
+type ProcessFunc func([]byte) ([]byte, error)
+
+// MessageFunc processes a message and returns the output message.
+type MessageFunc func(*Message) (*Message, error)
+
+// MessageNode is implemented by nodes that process and publish full
+// messages rather than bare payloads. BaseNode implements MessageNode.
+type MessageNode interface {
+	Node
+
+	// ProcessMessage takes a message, processes it, and returns the output.
+	ProcessMessage(msg *Message) (*Message, error)
+
+	// NotifyMessage sends a message to all subscribed nodes asynchronously.
+	NotifyMessage(msg *Message) error
+}
+
+var (
+	messageIDPrefix = newMessageIDPrefix()
+	messageIDSeq    uint64
+)
+
+func newMessageIDPrefix() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+func newMessageID() string {
+	return messageIDPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&messageIDSeq, 1), 10)
+}
+
+// NewMessage creates a message with a fresh ID and the current time.
+func NewMessage(source string, payload []byte) *Message {
+	return &Message{
+		ID:        newMessageID(),
+		Source:    source,
+		Timestamp: time.Now(),
+		Payload:   payload,
+	}
+}
+
+// Header returns the value of a header, or "" if it is not set.
+func (m *Message) Header(key string) string {
+	return m.Headers[key]
+}
+
+// SetHeader sets a header, allocating the header map if needed.
+func (m *Message) SetHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
+
+// Clone returns a copy of the message with its own header map. The payload
+// is shared.
+func (m *Message) Clone() *Message {
+	c := *m
+	if m.Headers != nil {
+		c.Headers = make(map[string]string, len(m.Headers))
+		for k, v := range m.Headers {
+			c.Headers[k] = v
+		}
+	}
+	return &c
+}
+
+// Derive returns a new message carrying payload that inherits the headers,
+// content type and timestamp of m, so trace context and event time follow
+// an event through a chain of nodes.
+func (m *Message) Derive(payload []byte) *Message {
+	c := m.Clone()
+	c.ID = newMessageID()
+	c.Payload = payload
+	c.batch = nil
+	return c
+}
+
+// AdaptProcessFunc adapts a payload ProcessFunc into a MessageFunc. The
+// output message is derived from the input message.
+func AdaptProcessFunc(fn ProcessFunc) MessageFunc {
+	return func(msg *Message) (*Message, error) {
+		out, err := fn(msg.Payload)
+		if out == nil && err != nil {
+			return nil, err
+		}
+		return msg.Derive(out), err
+	}
+}
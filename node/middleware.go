@@ -0,0 +1,33 @@
+package node
+
+// Middleware wraps a MessageFunc to add cross-cutting behavior such as
+// logging, metrics, validation or timing around a node's processing.
+type Middleware func(next MessageFunc) MessageFunc
+
+// PayloadMiddleware adapts middleware written against ProcessFunc into a
+// Middleware. The wrapped function sees only payloads; the output message is
+// derived from the input message.
+func PayloadMiddleware(mw func(next ProcessFunc) ProcessFunc) Middleware {
+	return func(next MessageFunc) MessageFunc {
+		return func(msg *Message) (*Message, error) {
+			inner := func(payload []byte) ([]byte, error) {
+				in := msg.Clone()
+				in.Payload = payload
+				out, err := next(in)
+				if out == nil {
+					return nil, err
+				}
+				return out.Payload, err
+			}
+			return AdaptProcessFunc(mw(inner))(msg)
+		}
+	}
+}
+
+// chainMiddleware wraps handler so that middleware[0] is the outermost layer.
+func chainMiddleware(handler MessageFunc, middleware []Middleware) MessageFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
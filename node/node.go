@@ -9,82 +9,126 @@
 // and ease of use.
 //
 // Main components:
-// 
-// - Node Interface: Defines the basic operations and properties that all
-//   node types must implement.
-// - BaseNode Struct: Provides common functionality that can be extended by
-//   specific node types.
-// - Lifecycle Management: Methods to create and delete nodes.
-// - Data Processing: Mechanism to process input data and return output.
-// - Subscription Management: Manage subscriptions to other nodes for event
-//   notifications.
-// - Event Notification: Notify all subscribed nodes asynchronously.
+//
+//   - Node Interface: Defines the basic operations and properties that all
+//     node types must implement.
+//   - BaseNode Struct: Provides common functionality that can be extended by
+//     specific node types.
+//   - Lifecycle Management: Methods to create and delete nodes.
+//   - Data Processing: Mechanism to process input data and return output.
+//   - Subscription Management: Manage subscriptions to other nodes for event
+//     notifications, optionally filtered by a predicate or a declarative
+//     expression such as `temp > 30 && site == "A"`.
+//   - Event Notification: Notify all subscribed nodes asynchronously.
+//   - Message Envelope: Message carries a payload together with its source,
+//     timestamp, ID, content type and headers such as trace context. Nodes
+//     implementing MessageNode process and publish full messages.
+//   - Typed Nodes: TypedNode[In, Out] decodes payloads into Go values and
+//     encodes outputs using a pluggable Codec (JSON, gob or protobuf).
+//   - Middleware: BaseNode.Use wraps processing with composable layers for
+//     logging, metrics, validation and other cross-cutting concerns.
+//   - Panic Isolation: Panics in process functions are recovered as
+//     *PanicError values and handled by a configurable PanicPolicy.
+//   - Delivery Retries: Subscriptions can retry failed deliveries with
+//     exponential backoff and jitter, classifying errors as retryable or
+//     permanent.
+//   - Dead Letters: Undeliverable events are recorded in a DeadLetterSink
+//     (another node, an in-memory ring or an append-only file) and can be
+//     redriven once the subscriber recovers.
+//   - Circuit Breakers: Per-subscription breakers stop hammering failing
+//     subscribers, optionally routing events to a fallback node.
+//   - Rate Limiting: Token-bucket limits on Process and on each subscription
+//     wait, reject with ErrRateLimited, or sample excess events.
+//   - Lifecycle: Nodes move through new, starting, running, stopping and
+//     stopped states; Create and Delete run OnStart and OnStop hooks.
+//     DeleteContext drains in-flight work before stopping.
+//   - Pause and Resume: Paused nodes buffer or reject incoming events and
+//     replay the buffer in order when resumed.
+//   - Runtime: Owns nodes by ID, rejects duplicate IDs, wires subscriptions
+//     by ID, and starts and shuts down all nodes in order.
+//   - Graph Analysis: AnalyzeGraph reports cycles, self-subscriptions,
+//     unreachable nodes and fan-out hotspots; SetCycleCheck and SetMaxHops
+//     guard against event storms at runtime.
+//   - Graph Export: ExportDOT and ExportMermaid render the subscription graph
+//     with per-subscription event counts and error rates.
+//   - Pipelines: NewPipeline builds linear and branching flows with Source,
+//     Then, Branch, Merge and Sink, started and stopped as one unit.
+//   - Forwarding: SetForwarding publishes process output to subscribers,
+//     optionally skipping empty outputs, splitting outputs into several
+//     events, publishing batches built with NewBatch and preventing echoes
+//     and loops.
+//   - Partitioned Nodes: PartitionedNode processes messages on N workers,
+//     keeping messages with the same key in order on one worker.
+//   - Key-value Store: KV and SetKVFunc give process functions a
+//     key-value handle scoped to the node, backed by a MemoryStore, a
+//     FileStore that survives restarts, or any KVStore, with range scans and
+//     snapshots.
 //
 // Example usage:
-// 
-//  package main
-// 
-//  import (
-//      "fmt"
-//      "github.com/lhemerly/Constellation/node"
-//  )
-// 
-//  func main() {
-//      // Create and initialize nodes
-//      node1 := network.NewBaseNode("node-1")
-//      node2 := network.NewBaseNode("node-2")
-// 
-//      if err := node1.Create(); err != nil {
-//          fmt.Printf("Error creating node1: %v\n", err)
-//          return
-//      }
-//      if err := node2.Create(); err != nil {
-//          fmt.Printf("Error creating node2: %v\n", err)
-//          return
-//      }
-// 
-//      // Set a custom process function for node2
-//      node2.SetProcessFunc(func(input []byte) ([]byte, error) {
-//          return []byte(fmt.Sprintf("Processed by node2: %s", input)), nil
-//      })
-// 
-//      // Subscribe node2 to node1
-//      if err := node1.Subscribe(node2); err != nil {
-//          fmt.Printf("Error subscribing node2 to node1: %v\n", err)
-//          return
-//      }
-// 
-//      // Notify event from node1 to its subscribers
-//      event := []byte("Hello from node1")
-//      if err := node1.Notify(event); err != nil {
-//          fmt.Printf("Error notifying event: %v\n", err)
-//      }
-// 
-//      // Process input directly on node1
-//      input := []byte("Direct input to node1")
-//      output, err := node1.Process(input)
-//      if err != nil {
-//          fmt.Printf("Error processing input on node1: %v\n", err)
-//      } else {
-//          fmt.Printf("Output from node1: %s\n", output)
-//      }
-// 
-//      // Ensure node2 received the event
-//      output, err = node2.Process(event)
-//      if err != nil {
-//          fmt.Printf("Error processing event on node2: %v\n", err)
-//      } else {
-//          fmt.Printf("Output from node2: %s\n", output)
-//      }
-// 
-//      // Clean up nodes
-//      if err := node1.Delete(); err != nil {
-//          fmt.Printf("Error deleting node1: %v\n", err)
-//      }
-//      if err := node2.Delete(); err != nil {
-//          fmt.Printf("Error deleting node2: %v\n", err)
-//      }
-//  }
+//
+//	package main
+//
+//	import (
+//	    "fmt"
+//	    "github.com/lhemerly/Constellation/node"
+//	)
+//
+//	func main() {
+//	    // Create and initialize nodes
+//	    node1 := network.NewBaseNode("node-1")
+//	    node2 := network.NewBaseNode("node-2")
+//
+//	    if err := node1.Create(); err != nil {
+//	        fmt.Printf("Error creating node1: %v\n", err)
+//	        return
+//	    }
+//	    if err := node2.Create(); err != nil {
+//	        fmt.Printf("Error creating node2: %v\n", err)
+//	        return
+//	    }
+//
+//	    // Set a custom process function for node2
+//	    node2.SetProcessFunc(func(input []byte) ([]byte, error) {
+//	        return []byte(fmt.Sprintf("Processed by node2: %s", input)), nil
+//	    })
+//
+//	    // Subscribe node2 to node1
+//	    if err := node1.Subscribe(node2); err != nil {
+//	        fmt.Printf("Error subscribing node2 to node1: %v\n", err)
+//	        return
+//	    }
+//
+//	    // Notify event from node1 to its subscribers
+//	    event := []byte("Hello from node1")
+//	    if err := node1.Notify(event); err != nil {
+//	        fmt.Printf("Error notifying event: %v\n", err)
+//	    }
+//
+//	    // Process input directly on node1
+//	    input := []byte("Direct input to node1")
+//	    output, err := node1.Process(input)
+//	    if err != nil {
+//	        fmt.Printf("Error processing input on node1: %v\n", err)
+//	    } else {
+//	        fmt.Printf("Output from node1: %s\n", output)
+//	    }
+//
+//	    // Ensure node2 received the event
+//	    output, err = node2.Process(event)
+//	    if err != nil {
+//	        fmt.Printf("Error processing event on node2: %v\n", err)
+//	    } else {
+//	        fmt.Printf("Output from node2: %s\n", output)
+//	    }
+//
+//	    // Clean up nodes
+//	    if err := node1.Delete(); err != nil {
+//	        fmt.Printf("Error deleting node1: %v\n", err)
+//	    }
+//	    if err := node2.Delete(); err != nil {
+//	        fmt.Printf("Error deleting node2: %v\n", err)
+//	    }
+//	}
 //
 // The node package is suitable for building scalable and high-performance
 // networked applications, simplifying node lifecycle management, inter-node
@@ -106,11 +150,14 @@ type Node interface {
 	GetID() string
 
 	// Subscribe adds a node to the subscription list for event notifications.
-	Subscribe(node Node) error
+	// Options such as WithFilter restrict which events the node receives.
+	Subscribe(node Node, opts ...SubscriptionOption) error
 
 	// Unsubscribe removes a node from the subscription list.
 	Unsubscribe(node Node) error
 
-	// Notify sends an event to all subscribed nodes asynchronously.
+	// Notify sends an event to all subscribed nodes asynchronously, skipping
+	// subscribers whose filter does not match it. Failed deliveries are
+	// reported in the returned error.
 	Notify(event []byte) error
 }
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrQuarantined is returned by Process on a node that has been quarantined
+// after repeated panics.
+var ErrQuarantined = errors.New("node quarantined")
+
+// PanicError is returned when a process function panics. It carries the
+// recovered value and the stack trace of the panicking goroutine.
+type PanicError struct {
+	NodeID string
+	Value  interface{}
+	Stack  []byte
+}
+
+func newPanicError(nodeID string, value interface{}) *PanicError {
+	return &PanicError{NodeID: nodeID, Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("node %s panicked: %v", e.NodeID, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// PanicAction selects what a node does after recovering a panic.
+type PanicAction int
+
+const (
+	// PanicContinue returns the panic as a *PanicError and keeps processing.
+	PanicContinue PanicAction = iota
+	// PanicQuarantine returns the panic as a *PanicError and quarantines the
+	// node once it has panicked QuarantineAfter times.
+	PanicQuarantine
+	// PanicRethrow re-panics with the *PanicError, crashing the caller.
+	PanicRethrow
+)
+
+// PanicPolicy configures how a node handles panics in its process function.
+type PanicPolicy struct {
+	Action PanicAction
+	// QuarantineAfter is the number of panics after which a node using
+	// PanicQuarantine stops processing. Zero means the first panic.
+	QuarantineAfter uint64
+}
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultPartitionQueue is the number of messages each worker of a
+// PartitionedNode queues before callers block.
+const DefaultPartitionQueue = 64
+
+// PartitionFunc extracts the partition key of a message.
+type PartitionFunc func(msg *Message) string
+
+// PartitionedNode is a BaseNode that processes messages on a fixed set of
+// workers. Messages with the same key always go to the same worker and are
+// processed, and their output forwarded, one at a time in the order they
+// entered the node; messages with keys on different workers run in
+// parallel.
+//
+// Process and ProcessMessage block until the worker has processed the
+// message and return its result, so errors still reach the publisher. The
+// workers start when the node is created and stop, after finishing the
+// messages already queued, when it is deleted. A worker waits for the
+// subscribers its output is forwarded to, so the node must not be
+// subscribed to itself, directly or through a cycle.
+type PartitionedNode struct {
+	*BaseNode
+	key       PartitionFunc
+	workers   int
+	processed []uint64 // atomic per-worker counters
+
+	mu     sync.RWMutex // guards queues against sends after close
+	queues []chan partitionJob
+	wg     sync.WaitGroup
+}
+
+type partitionJob struct {
+	run  func() (*Message, error)
+	done chan partitionResult
+}
+
+type partitionResult struct {
+	out *Message
+	err error
+}
+
+// NewPartitionedNode creates a PartitionedNode with a given ID, number of
+// workers, key extractor and process function. Fewer than one worker is
+// treated as one; a nil process function echoes its input.
+func NewPartitionedNode(id string, workers int, key PartitionFunc, fn ProcessFunc) *PartitionedNode {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &PartitionedNode{
+		BaseNode:  NewBaseNode(id),
+		key:       key,
+		workers:   workers,
+		processed: make([]uint64, workers),
+	}
+	if fn != nil {
+		p.SetProcessFunc(fn)
+	}
+	p.mutex.Lock()
+	p.executor = p.dispatch
+	p.mutex.Unlock()
+	p.OnStart(p.startWorkers)
+	p.OnStop(p.stopWorkers)
+	return p
+}
+
+// Workers returns the number of workers.
+func (p *PartitionedNode) Workers() int {
+	return p.workers
+}
+
+// Partition returns the worker that processes messages with key.
+func (p *PartitionedNode) Partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(p.workers))
+}
+
+// GetPartitionCounts returns the number of messages each worker has
+// processed.
+func (p *PartitionedNode) GetPartitionCounts() []uint64 {
+	counts := make([]uint64, p.workers)
+	for i := range counts {
+		counts[i] = atomic.LoadUint64(&p.processed[i])
+	}
+	return counts
+}
+
+// dispatch queues a message on the worker for its key and waits for the
+// result.
+func (p *PartitionedNode) dispatch(msg *Message, run func() (*Message, error)) (*Message, error) {
+	key := ""
+	if p.key != nil {
+		key = p.key(msg)
+	}
+	job := partitionJob{run: run, done: make(chan partitionResult, 1)}
+
+	p.mu.RLock()
+	if p.queues == nil {
+		p.mu.RUnlock()
+		return nil, &StateError{NodeID: p.id, State: p.GetState()}
+	}
+	p.queues[p.Partition(key)] <- job
+	p.mu.RUnlock()
+
+	res := <-job.done
+	return res.out, res.err
+}
+
+func (p *PartitionedNode) startWorkers() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queues = make([]chan partitionJob, p.workers)
+	for i := range p.queues {
+		p.queues[i] = make(chan partitionJob, DefaultPartitionQueue)
+		p.wg.Add(1)
+		go p.work(i, p.queues[i])
+	}
+	return nil
+}
+
+func (p *PartitionedNode) work(i int, queue chan partitionJob) {
+	defer p.wg.Done()
+	for job := range queue {
+		out, err := job.run()
+		atomic.AddUint64(&p.processed[i], 1)
+		job.done <- partitionResult{out, err}
+	}
+}
+
+// stopWorkers closes the queues and waits for the workers to finish the
+// messages already queued.
+func (p *PartitionedNode) stopWorkers() error {
+	p.mu.Lock()
+	queues := p.queues
+	p.queues = nil
+	p.mu.Unlock()
+	for _, queue := range queues {
+		close(queue)
+	}
+	p.wg.Wait()
+	return nil
+}
@@ -0,0 +1,130 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPaused is returned when a paused node rejects an event, either because
+// its PausePolicy uses PauseReject or because its pause buffer is full.
+var ErrPaused = errors.New("node paused")
+
+// DefaultPauseCapacity is the pause buffer size used when PausePolicy leaves
+// Capacity unset.
+const DefaultPauseCapacity = 1024
+
+// PauseMode selects what happens to events a paused node receives.
+type PauseMode int
+
+const (
+	// PauseBuffer holds events until the node resumes.
+	PauseBuffer PauseMode = iota
+	// PauseReject fails events with ErrPaused.
+	PauseReject
+)
+
+// PausePolicy configures how a paused node handles incoming events.
+type PausePolicy struct {
+	Mode PauseMode
+	// Capacity bounds the number of buffered events. Zero means
+	// DefaultPauseCapacity. Events beyond it fail with ErrPaused.
+	Capacity int
+}
+
+// SetPausePolicy sets how the node handles events while it is paused.
+func (n *BaseNode) SetPausePolicy(policy PausePolicy) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.pausePolicy = policy
+}
+
+// Pause stops the node from processing events. Until Resume is called,
+// incoming events are buffered or rejected according to the node's
+// PausePolicy. The node can still notify its subscribers while paused.
+func (n *BaseNode) Pause() error {
+	return n.transition(StatePaused)
+}
+
+// Resume processes the events buffered while the node was paused, in the
+// order they arrived, and then moves the node back to the running state.
+// Events arriving while the buffer is replayed are queued behind it. The
+// outputs of buffered events are forwarded if forwarding is enabled and
+// discarded otherwise; their errors are returned joined together.
+func (n *BaseNode) Resume() error {
+	n.resumeMu.Lock()
+	defer n.resumeMu.Unlock()
+
+	if state := n.GetState(); state != StatePaused {
+		return &TransitionError{NodeID: n.id, From: state, To: StateRunning}
+	}
+
+	var errs []error
+	for {
+		n.mutex.Lock()
+		if n.state != StatePaused {
+			// Deleted while resuming; the rest of the buffer is abandoned.
+			n.mutex.Unlock()
+			return errors.Join(errs...)
+		}
+		if len(n.paused) == 0 {
+			n.state = StateRunning
+			n.mutex.Unlock()
+			return errors.Join(errs...)
+		}
+		held := n.paused[0]
+		n.paused = n.paused[1:]
+		chain, limiter, forward, exec := n.chain, n.limiter, n.forward, n.executor
+		key := n.inflight.add(held.Message, "")
+		n.mutex.Unlock()
+
+		if n.IsQuarantined() {
+			errs = append(errs, fmt.Errorf("node %s: %w", n.id, ErrQuarantined))
+		} else if _, err := n.execute(exec, held.Message, chain, limiter, forward); err != nil {
+			errs = append(errs, err)
+		}
+		n.inflight.done(key)
+	}
+}
+
+// GetBufferedCount returns the number of events buffered while the node is
+// paused.
+func (n *BaseNode) GetBufferedCount() int {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return len(n.paused)
+}
+
+// hold buffers or rejects a message sent to a paused node.
+func (n *BaseNode) hold(msg *Message) (*Message, error) {
+	n.mutex.Lock()
+	if n.state != StatePaused {
+		// Resumed or stopped since the caller looked.
+		n.mutex.Unlock()
+		return n.ProcessMessage(msg)
+	}
+	defer n.mutex.Unlock()
+
+	policy := n.pausePolicy
+	if policy.Mode == PauseReject {
+		return nil, fmt.Errorf("node %s: %w", n.id, ErrPaused)
+	}
+	capacity := policy.Capacity
+	if capacity <= 0 {
+		capacity = DefaultPauseCapacity
+	}
+	if len(n.paused) >= capacity {
+		return nil, fmt.Errorf("node %s: pause buffer full: %w", n.id, ErrPaused)
+	}
+	n.paused = append(n.paused, InFlight{Message: msg, Since: time.Now()})
+	return nil, nil
+}
+
+// takePaused removes and returns the events buffered while paused.
+func (n *BaseNode) takePaused() []InFlight {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	held := n.paused
+	n.paused = nil
+	return held
+}
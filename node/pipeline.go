@@ -0,0 +1,342 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPipelineNotStarted is returned by Send on a pipeline that is not
+// running.
+var ErrPipelineNotStarted = errors.New("pipeline not started")
+
+// Pipeline builds a linear or branching flow of nodes. Each call connects
+// the new stage to the current tail of the pipeline:
+//
+//	p := NewPipeline().
+//		Source(parse).
+//		Then(enrich).
+//		Branch(isAlert, alerts, metrics).
+//		Merge(audit).
+//		Sink(archive)
+//	if err := p.Start(); err != nil { ... }
+//	err := p.Send(event)
+//
+// Building only records the connections; Start subscribes the stages to
+// each other once the pipeline is valid. Stages with downstream stages
+// forward their output to them, keeping any ForwardConfig set on the stage
+// beforehand, so process functions only transform events. Builder errors
+// are collected and reported by Err and Start. Stages other than sinks must
+// be built on BaseNode.
+type Pipeline struct {
+	stages     []Node // in the order they were added
+	sources    []Node
+	tails      []Node
+	edges      []pipelineEdge  // connections, subscribed by Start
+	downstream map[string]bool // IDs of stages with downstream stages
+	sealed     bool
+	errs       []error
+	rt         *Runtime
+}
+
+// pipelineEdge is a subscription between two stages.
+type pipelineEdge struct {
+	from, to Node
+	opts     []SubscriptionOption
+}
+
+// NewPipeline creates an empty pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{downstream: make(map[string]bool)}
+}
+
+// Source sets the stages events enter the pipeline through. It must be
+// the first call.
+func (p *Pipeline) Source(nodes ...Node) *Pipeline {
+	if len(p.stages) > 0 {
+		return p.fail("Source must be the first stage")
+	}
+	if len(nodes) == 0 {
+		return p.fail("Source needs at least one node")
+	}
+	for _, n := range nodes {
+		if !p.add(n) {
+			return p
+		}
+	}
+	p.sources = nodes
+	p.tails = nodes
+	return p
+}
+
+// Then connects every tail of the pipeline to n and makes it the new tail.
+// After a Branch, Then merges the branches into n.
+func (p *Pipeline) Then(n Node) *Pipeline {
+	if !p.extendable() || !p.add(n) {
+		return p
+	}
+	for _, tail := range p.tails {
+		p.connect(tail, n)
+	}
+	p.tails = []Node{n}
+	return p
+}
+
+// ThenFunc adds a BaseNode with the given ID that processes events with fn.
+func (p *Pipeline) ThenFunc(id string, fn ProcessFunc) *Pipeline {
+	n := NewBaseNode(id)
+	n.SetProcessFunc(fn)
+	return p.Then(n)
+}
+
+// Branch routes events from every tail to match if pred returns true for
+// their payload and to other otherwise. Other may be nil to drop
+// non-matching events. The branches become the new tails.
+func (p *Pipeline) Branch(pred Filter, match, other Node) *Pipeline {
+	if !p.extendable() {
+		return p
+	}
+	if pred == nil {
+		return p.fail("Branch needs a predicate")
+	}
+	if !p.add(match) {
+		return p
+	}
+	tails := []Node{match}
+	if other != nil {
+		if !p.add(other) {
+			return p
+		}
+		tails = append(tails, other)
+	}
+	not := func(payload []byte) bool { return !pred(payload) }
+	for _, tail := range p.tails {
+		p.connect(tail, match, WithFilter(pred))
+		if other != nil {
+			p.connect(tail, other, WithFilter(not))
+		}
+	}
+	p.tails = tails
+	return p
+}
+
+// Merge joins every tail of the pipeline into n. It is equivalent to Then
+// and reads better after Branch.
+func (p *Pipeline) Merge(n Node) *Pipeline {
+	return p.Then(n)
+}
+
+// Sink adds a final stage. No stages can be added after it.
+func (p *Pipeline) Sink(n Node) *Pipeline {
+	p.Then(n)
+	p.sealed = true
+	return p
+}
+
+// Err returns the errors found while building the pipeline, joined.
+func (p *Pipeline) Err() error {
+	return errors.Join(p.errs...)
+}
+
+// Stages returns the pipeline's stages in the order they were added.
+func (p *Pipeline) Stages() []Node {
+	return append([]Node(nil), p.stages...)
+}
+
+// Runtime returns the runtime owning the pipeline's stages, or nil before
+// Start.
+func (p *Pipeline) Runtime() *Runtime {
+	return p.rt
+}
+
+// Start validates the pipeline, subscribes its stages to each other and
+// starts them, downstream stages first so that no stage publishes to one
+// that is not running yet. If any step fails, the subscriptions and
+// forwarding set up by Start are removed again.
+func (p *Pipeline) Start() error {
+	if p.rt != nil {
+		return ErrRuntimeStarted
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+	var (
+		wired     []pipelineEdge
+		forwarded []*BaseNode
+	)
+	undo := func(err error) error {
+		for _, b := range forwarded {
+			b.DisableForwarding()
+		}
+		for i := len(wired) - 1; i >= 0; i-- {
+			from := wired[i].from.(baseNoder).base()
+			if from.GetSubscription(wired[i].to.GetID()) != nil {
+				from.Unsubscribe(wired[i].to)
+			}
+		}
+		return err
+	}
+	for _, e := range p.edges {
+		if err := e.from.Subscribe(e.to, e.opts...); err != nil {
+			return undo(fmt.Errorf("pipeline: connect %s -> %s: %w", e.from.GetID(), e.to.GetID(), err))
+		}
+		wired = append(wired, e)
+	}
+	for _, n := range p.stages {
+		if p.downstream[n.GetID()] {
+			b := n.(baseNoder).base()
+			if _, ok := b.GetForwarding(); !ok {
+				b.SetForwarding(ForwardConfig{})
+				forwarded = append(forwarded, b)
+			}
+		}
+	}
+
+	rt := NewRuntime()
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		if err := rt.Add(p.stages[i]); err != nil {
+			return undo(err)
+		}
+	}
+	if err := rt.Start(); err != nil {
+		return undo(err)
+	}
+	p.rt = rt
+	return nil
+}
+
+// Stop stops the pipeline's stages, sources first, draining each until
+// ctx is done.
+func (p *Pipeline) Stop(ctx context.Context) error {
+	if p.rt == nil {
+		return nil
+	}
+	return p.rt.Shutdown(ctx)
+}
+
+// Send passes an event to every source of the pipeline and waits for it to
+// flow through. Errors from any stage are reported, wrapped in the
+// *DeliveryError of each hop that led to them.
+func (p *Pipeline) Send(payload []byte) error {
+	return p.SendMessage(NewMessage("", payload))
+}
+
+// SendMessage passes a message to every source of the pipeline and waits
+// for it to flow through. Each source receives its own copy.
+func (p *Pipeline) SendMessage(msg *Message) error {
+	if p.rt == nil {
+		return ErrPipelineNotStarted
+	}
+	var errs []error
+	for _, source := range p.sources {
+		var err error
+		if mn, ok := source.(MessageNode); ok {
+			_, err = mn.ProcessMessage(msg.Clone())
+		} else {
+			_, err = source.Process(msg.Payload)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pipeline source %s: %w", source.GetID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fail records a builder error.
+func (p *Pipeline) fail(format string, args ...interface{}) *Pipeline {
+	p.errs = append(p.errs, fmt.Errorf("pipeline: "+format, args...))
+	return p
+}
+
+// extendable reports whether stages can be added to the tails.
+func (p *Pipeline) extendable() bool {
+	switch {
+	case len(p.sources) == 0:
+		p.fail("Source must be the first stage")
+		return false
+	case p.sealed:
+		p.fail("no stages can follow Sink")
+		return false
+	}
+	return true
+}
+
+// add registers a new stage.
+func (p *Pipeline) add(n Node) bool {
+	if n == nil {
+		p.fail("nil stage")
+		return false
+	}
+	for _, stage := range p.stages {
+		if stage.GetID() == n.GetID() {
+			if sameNode(stage, n) {
+				p.fail("stage %s added twice", n.GetID())
+			} else {
+				p.fail("stage %s: %v", n.GetID(), ErrDuplicateID)
+			}
+			return false
+		}
+	}
+	p.stages = append(p.stages, n)
+	return true
+}
+
+// connect records a subscription of to to from for Start.
+func (p *Pipeline) connect(from, to Node, opts ...SubscriptionOption) {
+	p.edges = append(p.edges, pipelineEdge{from: from, to: to, opts: opts})
+	p.downstream[from.GetID()] = true
+}
+
+// validate checks the pipeline before it starts.
+func (p *Pipeline) validate() error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+	if len(p.sources) == 0 {
+		return errors.New("pipeline: no source")
+	}
+	var errs []error
+	for _, n := range p.stages {
+		if _, ok := n.(baseNoder); !ok && p.downstream[n.GetID()] {
+			errs = append(errs, fmt.Errorf("pipeline: stage %s has downstream stages but is not built on BaseNode", n.GetID()))
+		}
+	}
+	if cycles := p.cycles(); len(cycles) > 0 {
+		errs = append(errs, fmt.Errorf("pipeline: cycle through %v", cycles))
+	}
+	return errors.Join(errs...)
+}
+
+// cycles returns the cycles the pipeline's connections would close, taking
+// the existing subscriptions of its stages into account.
+func (p *Pipeline) cycles() [][]string {
+	g := buildGraph(p.stages)
+	for _, e := range p.edges {
+		from, to := e.from.GetID(), e.to.GetID()
+		if !containsID(g.edges[from], to) {
+			g.edges[from] = append(g.edges[from], to)
+		}
+	}
+	var cycles [][]string
+	for _, id := range g.ids {
+		if containsID(g.edges[id], id) {
+			cycles = append(cycles, []string{id})
+		}
+	}
+	for _, scc := range g.components() {
+		if len(scc) > 1 {
+			cycles = append(cycles, g.cycleThrough(scc))
+		}
+	}
+	return cycles
+}
+
+// containsID reports whether ids contains id.
+func containsID(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
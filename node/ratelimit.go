@@ -0,0 +1,159 @@
+package node
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when an event exceeds a rate limit using
+// RateLimitReject.
+var ErrRateLimited = errors.New("rate limited")
+
+// errSampledOut signals an event dropped by a rate limit using
+// RateLimitSample. It never escapes the package.
+var errSampledOut = errors.New("sampled out")
+
+// RateLimitMode selects what happens to events that exceed a rate limit.
+type RateLimitMode int
+
+const (
+	// RateLimitWait blocks until the event fits within the limit.
+	RateLimitWait RateLimitMode = iota
+	// RateLimitReject fails the event with ErrRateLimited.
+	RateLimitReject
+	// RateLimitSample silently drops the event.
+	RateLimitSample
+)
+
+// RateLimit configures a token bucket.
+type RateLimit struct {
+	// Rate is the number of events allowed per second. Zero or less means
+	// unlimited.
+	Rate float64
+	// Burst is the bucket capacity. Zero means max(1, Rate).
+	Burst int
+	// Mode selects the behavior when the limit is exceeded.
+	Mode RateLimitMode
+}
+
+// RateLimiter is a token-bucket rate limiter whose limit can be changed at
+// runtime. It is safe for concurrent use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	burst   float64
+	tokens  float64
+	last    time.Time
+	dropped uint64
+}
+
+// NewRateLimiter creates a rate limiter with a full bucket.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	l := &RateLimiter{last: time.Now()}
+	l.SetLimit(limit)
+	l.tokens = l.burst
+	return l
+}
+
+// SetLimit changes the limit. Tokens already in the bucket are kept, up to
+// the new burst size.
+func (l *RateLimiter) SetLimit(limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.limit = limit
+	l.burst = float64(limit.Burst)
+	if limit.Burst <= 0 {
+		l.burst = math.Max(1, limit.Rate)
+	}
+	l.tokens = math.Min(l.tokens, l.burst)
+}
+
+// Limit returns the current limit.
+func (l *RateLimiter) Limit() RateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Dropped returns the number of events rejected or sampled out.
+func (l *RateLimiter) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
+
+// Allow takes a token if one is available and reports whether it did.
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit.Rate <= 0 {
+		return true
+	}
+	l.refill(time.Now())
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait blocks until a token is available and takes it.
+func (l *RateLimiter) Wait() {
+	l.mu.Lock()
+	if l.limit.Rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	l.refill(time.Now())
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.limit.Rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// acquire admits an event according to the limiter's mode.
+func (l *RateLimiter) acquire() error {
+	l.mu.Lock()
+	mode := l.limit.Mode
+	l.mu.Unlock()
+
+	if mode == RateLimitWait {
+		l.Wait()
+		return nil
+	}
+	if l.Allow() {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.dropped++
+	l.mu.Unlock()
+	if mode == RateLimitSample {
+		return errSampledOut
+	}
+	return ErrRateLimited
+}
+
+// refill adds the tokens accrued since the last refill. It must be called
+// with mu held.
+func (l *RateLimiter) refill(now time.Time) {
+	if l.limit.Rate > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.limit.Rate)
+	}
+	l.last = now
+}
+
+// WithRateLimit limits the rate of deliveries to the subscriber. Each
+// delivery attempt, including retries, takes a token.
+func WithRateLimit(limit RateLimit) SubscriptionOption {
+	return func(s *subscription) error {
+		s.limiter = NewRateLimiter(limit)
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package node
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrPermanent marks a delivery failure that must not be retried. Wrap an
+// error with Permanent, or return an error for which errors.Is(err,
+// ErrPermanent) holds.
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent wraps err so that retry policies treat it as non-retryable.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string        { return e.err.Error() }
+func (e *permanentError) Unwrap() error        { return e.err }
+func (e *permanentError) Is(target error) bool { return target == ErrPermanent }
+
+// RetryableError is implemented by errors that know whether the operation
+// that produced them may be retried.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// IsRetryable reports whether a delivery that failed with err may be
+// retried. Errors matching ErrPermanent, ErrQuarantined, ErrCircuitOpen or
+// ErrTTLExceeded, decode errors and errors whose Retryable method returns
+// false are permanent; all other errors are retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrPermanent) || errors.Is(err, ErrQuarantined) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrTTLExceeded) {
+		return false
+	}
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return false
+	}
+	var re RetryableError
+	if errors.As(err, &re) {
+		return re.Retryable()
+	}
+	return true
+}
+
+// RetryPolicy configures how failed deliveries to a subscriber are retried.
+// Backoff between attempts grows exponentially from InitialBackoff by
+// Multiplier, capped at MaxBackoff, and is randomized by Jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of delivery attempts, including the
+	// first. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier scales the delay after each attempt. Zero means 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction in either
+	// direction, e.g. 0.2 for ±20%.
+	Jitter float64
+	// Retryable classifies errors. Nil means IsRetryable.
+	Retryable func(error) bool
+}
+
+// Backoff returns the delay to wait after the given failed attempt, counting
+// from 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// WithRetry retries failed deliveries to the subscriber according to policy.
+// Retries run on the subscriber's own delivery goroutine, so they never delay
+// delivery to other subscribers.
+func WithRetry(policy RetryPolicy) SubscriptionOption {
+	return func(s *subscription) error {
+		s.retry = policy
+		return nil
+	}
+}
@@ -0,0 +1,240 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrDuplicateID is returned when a node is added to a Runtime, or subscribed
+// to a node, while a different node with the same ID is already there.
+var ErrDuplicateID = errors.New("duplicate node ID")
+
+// ErrNodeNotFound is returned when a Runtime has no node with a given ID.
+var ErrNodeNotFound = errors.New("node not found")
+
+// ErrRuntimeStarted is returned by Start on a runtime that is already
+// running.
+var ErrRuntimeStarted = errors.New("runtime already started")
+
+// Runtime owns a set of nodes by ID. It starts them in the order they were
+// added, stops them in reverse order, and wires subscriptions between them
+// by ID. It is safe for concurrent use.
+type Runtime struct {
+	mu      sync.RWMutex
+	nodes   map[string]Node
+	order   []string // IDs in the order they were added
+	started bool
+}
+
+// NewRuntime creates an empty runtime.
+func NewRuntime() *Runtime {
+	return &Runtime{nodes: make(map[string]Node)}
+}
+
+// Add registers nodes with the runtime. It fails with ErrDuplicateID, adding
+// none of the nodes, if any ID is already taken. Nodes added to a started
+// runtime are created immediately.
+func (r *Runtime) Add(nodes ...Node) error {
+	r.mu.Lock()
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		id := node.GetID()
+		if _, ok := r.nodes[id]; ok || seen[id] {
+			r.mu.Unlock()
+			return fmt.Errorf("add %s: %w", id, ErrDuplicateID)
+		}
+		seen[id] = true
+	}
+	for _, node := range nodes {
+		r.nodes[node.GetID()] = node
+		r.order = append(r.order, node.GetID())
+	}
+	started := r.started
+	r.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+	var errs []error
+	for _, node := range nodes {
+		if err := node.Create(); err != nil {
+			errs = append(errs, fmt.Errorf("create %s: %w", node.GetID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns the node with the given ID, or nil if there is none.
+func (r *Runtime) Get(id string) Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nodes[id]
+}
+
+// IDs returns the IDs of the runtime's nodes in the order they were added.
+func (r *Runtime) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+// Nodes returns the runtime's nodes in the order they were added.
+func (r *Runtime) Nodes() []Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	nodes := make([]Node, len(r.order))
+	for i, id := range r.order {
+		nodes[i] = r.nodes[id]
+	}
+	return nodes
+}
+
+// Len returns the number of nodes in the runtime.
+func (r *Runtime) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.order)
+}
+
+// Remove unsubscribes a node from every other node in the runtime and
+// removes it. If the runtime is started the node is deleted.
+func (r *Runtime) Remove(id string) error {
+	r.mu.Lock()
+	node, ok := r.nodes[id]
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("remove %s: %w", id, ErrNodeNotFound)
+	}
+	delete(r.nodes, id)
+	for i, other := range r.order {
+		if other == id {
+			r.order = append(r.order[:i:i], r.order[i+1:]...)
+			break
+		}
+	}
+	publishers := make([]Node, 0, len(r.nodes))
+	for _, other := range r.nodes {
+		publishers = append(publishers, other)
+	}
+	started := r.started
+	r.mu.Unlock()
+
+	var errs []error
+	for _, publisher := range publishers {
+		if err := publisher.Unsubscribe(node); err != nil {
+			errs = append(errs, fmt.Errorf("unsubscribe %s from %s: %w", id, publisher.GetID(), err))
+		}
+	}
+	if started {
+		if err := node.Delete(); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Connect subscribes the node with ID to to the node with ID from.
+func (r *Runtime) Connect(from, to string, opts ...SubscriptionOption) error {
+	publisher, subscriber, err := r.pair(from, to)
+	if err != nil {
+		return err
+	}
+	return publisher.Subscribe(subscriber, opts...)
+}
+
+// Disconnect unsubscribes the node with ID to from the node with ID from.
+func (r *Runtime) Disconnect(from, to string) error {
+	publisher, subscriber, err := r.pair(from, to)
+	if err != nil {
+		return err
+	}
+	return publisher.Unsubscribe(subscriber)
+}
+
+// pair looks up a publisher and subscriber by ID.
+func (r *Runtime) pair(from, to string) (Node, Node, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	publisher, ok := r.nodes[from]
+	if !ok {
+		return nil, nil, fmt.Errorf("connect %s -> %s: %s: %w", from, to, from, ErrNodeNotFound)
+	}
+	subscriber, ok := r.nodes[to]
+	if !ok {
+		return nil, nil, fmt.Errorf("connect %s -> %s: %s: %w", from, to, to, ErrNodeNotFound)
+	}
+	return publisher, subscriber, nil
+}
+
+// Start creates every node in the order they were added. If a node fails
+// to start, the nodes already started are deleted in reverse order and the
+// error is returned.
+func (r *Runtime) Start() error {
+	r.mu.Lock()
+	if r.started {
+		r.mu.Unlock()
+		return ErrRuntimeStarted
+	}
+	r.started = true
+	nodes := make([]Node, len(r.order))
+	for i, id := range r.order {
+		nodes[i] = r.nodes[id]
+	}
+	r.mu.Unlock()
+
+	for i, node := range nodes {
+		if err := node.Create(); err != nil {
+			errs := []error{fmt.Errorf("create %s: %w", node.GetID(), err)}
+			for j := i - 1; j >= 0; j-- {
+				if err := nodes[j].Delete(); err != nil {
+					errs = append(errs, fmt.Errorf("delete %s: %w", nodes[j].GetID(), err))
+				}
+			}
+			r.mu.Lock()
+			r.started = false
+			r.mu.Unlock()
+			return errors.Join(errs...)
+		}
+	}
+	return nil
+}
+
+// Shutdown deletes every node in the reverse of the order they were added,
+// so that nodes stop before the nodes they were started after. Nodes that
+// support draining, such as BaseNode, drain until ctx is done. All nodes are
+// deleted even if some fail; their errors are returned joined together.
+func (r *Runtime) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.started {
+		r.mu.Unlock()
+		return nil
+	}
+	r.started = false
+	nodes := make([]Node, len(r.order))
+	for i, id := range r.order {
+		nodes[i] = r.nodes[id]
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if err := deleteNode(ctx, nodes[i]); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", nodes[i].GetID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteNode deletes a node, draining it until ctx is done if it supports
+// draining.
+func deleteNode(ctx context.Context, node Node) error {
+	if d, ok := node.(interface {
+		DeleteContext(context.Context) (DrainReport, error)
+	}); ok {
+		_, err := d.DeleteContext(ctx)
+		return err
+	}
+	return node.Delete()
+}
@@ -0,0 +1,198 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// subscription holds a subscribed node together with its delivery settings.
+type subscription struct {
+	delivered    uint64 // Atomic count of events delivered
+	failed       uint64 // Atomic count of events that could not be delivered
+	sampled      uint64 // Atomic count of events dropped by the rate limit
+	fellBack     uint64 // Atomic count of events sent to the breaker's fallback
+	lastLatency  int64  // Atomic duration of the last delivery, in nanoseconds
+	node         Node
+	subscribedAt time.Time
+	filter       Filter
+	filterDesc   string
+	retry        RetryPolicy
+	breaker      *circuitBreaker
+	limiter      *RateLimiter
+}
+
+// SubscriptionOption configures a subscription created by Subscribe.
+type SubscriptionOption func(*subscription) error
+
+// WithFilter restricts delivery to events for which filter returns true.
+func WithFilter(filter Filter) SubscriptionOption {
+	return func(s *subscription) error {
+		if filter == nil {
+			return fmt.Errorf("nil filter")
+		}
+		s.filter = filter
+		s.filterDesc = "func"
+		return nil
+	}
+}
+
+// WithFilterExpr restricts delivery to events matching a declarative
+// expression. See CompileFilter for the expression syntax.
+func WithFilterExpr(expr string) SubscriptionOption {
+	return func(s *subscription) error {
+		filter, err := CompileFilter(expr)
+		if err != nil {
+			return err
+		}
+		s.filter = filter
+		s.filterDesc = expr
+		return nil
+	}
+}
+
+// SubscriptionStats counts the events a publisher sent to one subscriber.
+type SubscriptionStats struct {
+	// Delivered is the number of events the subscriber processed.
+	Delivered uint64
+	// Failed is the number of events that could not be delivered after
+	// retries, including ones skipped by an open circuit breaker without a
+	// fallback node.
+	Failed uint64
+	// Sampled is the number of events dropped by a RateLimitSample limit.
+	Sampled uint64
+	// Fallback is the number of events an open circuit breaker sent to its
+	// fallback node instead of the subscriber.
+	Fallback uint64
+}
+
+// ErrorRate returns the fraction of events that failed, or zero if none
+// were sent.
+func (s SubscriptionStats) ErrorRate() float64 {
+	total := s.Delivered + s.Failed
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(total)
+}
+
+// stats returns a snapshot of the subscription's counters.
+func (s *subscription) stats() SubscriptionStats {
+	return SubscriptionStats{
+		Delivered: atomic.LoadUint64(&s.delivered),
+		Failed:    atomic.LoadUint64(&s.failed),
+		Sampled:   atomic.LoadUint64(&s.sampled),
+		Fallback:  atomic.LoadUint64(&s.fellBack),
+	}
+}
+
+// SubscriptionInfo is a snapshot of a subscription.
+type SubscriptionInfo struct {
+	// ID is the subscriber's ID.
+	ID   string
+	Node Node
+	// SubscribedAt is when Subscribe last added or replaced the
+	// subscription.
+	SubscribedAt time.Time
+	// Filter is the expression given to WithFilterExpr, "func" for a filter
+	// given to WithFilter, or empty if the subscription is unfiltered.
+	Filter string
+	SubscriptionStats
+	// LastLatency is how long the most recent delivery took, including
+	// retries, or zero if nothing was delivered yet.
+	LastLatency time.Duration
+	// Breaker is the state of the subscription's circuit breaker, if
+	// HasBreaker is set.
+	Breaker    BreakerState
+	HasBreaker bool
+	// RateLimit is the subscription's rate limit, if RateLimited is set.
+	RateLimit   RateLimit
+	RateLimited bool
+}
+
+// info returns a snapshot of the subscription.
+func (s *subscription) info() SubscriptionInfo {
+	info := SubscriptionInfo{
+		ID:                s.node.GetID(),
+		Node:              s.node,
+		SubscribedAt:      s.subscribedAt,
+		Filter:            s.filterDesc,
+		SubscriptionStats: s.stats(),
+		LastLatency:       time.Duration(atomic.LoadInt64(&s.lastLatency)),
+	}
+	if s.breaker != nil {
+		info.Breaker, info.HasBreaker = s.breaker.currentState(), true
+	}
+	if s.limiter != nil {
+		info.RateLimit, info.RateLimited = s.limiter.Limit(), true
+	}
+	return info
+}
+
+// Subscriptions returns snapshots of the node's subscriptions, sorted by
+// subscriber ID.
+func (n *BaseNode) Subscriptions() []SubscriptionInfo {
+	subs := n.sortedSubscriptions()
+	infos := make([]SubscriptionInfo, len(subs))
+	for i, sub := range subs {
+		infos[i] = sub.info()
+	}
+	return infos
+}
+
+// RangeSubscriptions calls fn with a snapshot of each subscription, sorted
+// by subscriber ID, until fn returns false. The sorted list of
+// subscriptions is copied when the iteration starts, so subscriptions added
+// or removed during it are not reflected; each snapshot, with its counters
+// and breaker state, is only taken when the iteration reaches it, so
+// stopping early skips building the rest.
+func (n *BaseNode) RangeSubscriptions(fn func(SubscriptionInfo) bool) {
+	for _, sub := range n.sortedSubscriptions() {
+		if !fn(sub.info()) {
+			return
+		}
+	}
+}
+
+// SubscriptionCount returns the number of nodes subscribed to the node.
+func (n *BaseNode) SubscriptionCount() int {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return len(n.subscriptions)
+}
+
+// sortedSubscriptions returns the node's subscriptions sorted by ID.
+func (n *BaseNode) sortedSubscriptions() []*subscription {
+	n.mutex.RLock()
+	subs := make([]*subscription, 0, len(n.subscriptions))
+	for _, sub := range n.subscriptions {
+		subs = append(subs, sub)
+	}
+	n.mutex.RUnlock()
+	sort.Slice(subs, func(i, j int) bool { return subs[i].node.GetID() < subs[j].node.GetID() })
+	return subs
+}
+
+// matches reports whether the event passes the subscription's filter.
+func (s *subscription) matches(event []byte) bool {
+	return s.filter == nil || s.filter(event)
+}
+
+// sameNode reports whether a and b are the same node. Nodes built on
+// BaseNode are compared by their BaseNode, so wrappers around one node are
+// the same node.
+func sameNode(a, b Node) (same bool) {
+	ba, aok := a.(baseNoder)
+	bb, bok := b.(baseNoder)
+	if aok && bok {
+		return ba.base() == bb.base()
+	}
+	// Comparing nodes of uncomparable types panics; they are not the same.
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
@@ -0,0 +1,17 @@
+package node_test
+
+import (
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// createNodes calls Create on every node, failing the test on error.
+func createNodes(t *testing.T, nodes ...node.Node) {
+	t.Helper()
+	for _, n := range nodes {
+		if err := n.Create(); err != nil {
+			t.Fatalf("%s: Create() error = %v", n.GetID(), err)
+		}
+	}
+}
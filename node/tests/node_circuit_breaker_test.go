@@ -0,0 +1,133 @@
+package node_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeCircuitBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	publisher := node.NewBaseNode("publisher")
+	enricher := node.NewBaseNode("enricher")
+	createNodes(t, publisher, enricher)
+	sink := node.NewRingSink(10)
+	publisher.SetDeadLetterSink(sink)
+
+	var healthy int32
+	enricher.SetProcessFunc(func(input []byte) ([]byte, error) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			return nil, errors.New("enricher down")
+		}
+		return input, nil
+	})
+
+	var mu sync.Mutex
+	var transitions []string
+	config := node.CircuitBreakerConfig{
+		FailureThreshold: 3,
+		Cooldown:         cooldown,
+		OnStateChange: func(e node.BreakerEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			if e.PublisherID != "publisher" || e.SubscriberID != "enricher" {
+				t.Errorf("BreakerEvent = %+v", e)
+			}
+			transitions = append(transitions, e.From.String()+"->"+e.To.String())
+		},
+	}
+	if err := publisher.Subscribe(enricher, node.WithCircuitBreaker(config)); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		publisher.Notify([]byte("event"))
+	}
+	if got := enricher.GetEventCount(); got != 3 {
+		t.Errorf("enricher processed %d events, want 3 before the breaker opened", got)
+	}
+	if state, _ := publisher.GetBreakerState("enricher"); state != node.BreakerOpen {
+		t.Fatalf("breaker state = %v, want open", state)
+	}
+	err := publisher.Notify([]byte("event"))
+	if !errors.Is(err, node.ErrCircuitOpen) {
+		t.Errorf("Notify() while open error = %v, want ErrCircuitOpen", err)
+	}
+	var skipped int
+	for _, dl := range sink.Records() {
+		if dl.Reason == node.ReasonCircuitOpen {
+			skipped++
+		}
+	}
+	if skipped != 8 {
+		t.Errorf("circuit_open dead letters = %d, want 8", skipped)
+	}
+
+	// A failed trial delivery reopens the breaker.
+	time.Sleep(cooldown)
+	publisher.Notify([]byte("event"))
+	if state, _ := publisher.GetBreakerState("enricher"); state != node.BreakerOpen {
+		t.Fatalf("breaker state after failed trial = %v, want open", state)
+	}
+
+	// A successful trial delivery closes it.
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(cooldown)
+	if err := publisher.Notify([]byte("event")); err != nil {
+		t.Fatalf("Notify() trial error = %v", err)
+	}
+	if state, _ := publisher.GetBreakerState("enricher"); state != node.BreakerClosed {
+		t.Fatalf("breaker state after successful trial = %v, want closed", state)
+	}
+
+	want := "closed->open,open->half-open,half-open->open,open->half-open,half-open->closed"
+	mu.Lock()
+	got := strings.Join(transitions, ",")
+	mu.Unlock()
+	if got != want {
+		t.Errorf("transitions = %s, want %s", got, want)
+	}
+
+	if _, ok := publisher.GetBreakerState("missing"); ok {
+		t.Error("GetBreakerState(missing) ok = true, want false")
+	}
+}
+
+func TestBaseNodeCircuitBreakerFallback(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	primary := node.NewBaseNode("primary")
+	fallback := node.NewBaseNode("fallback")
+	createNodes(t, publisher, primary, fallback)
+	primary.SetProcessFunc(func([]byte) ([]byte, error) {
+		return nil, errors.New("primary down")
+	})
+
+	config := node.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Hour, Fallback: fallback}
+	if err := publisher.Subscribe(primary, node.WithCircuitBreaker(config)); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	if err := publisher.Notify([]byte("first")); err == nil {
+		t.Fatal("Notify() error = nil, want the failure that opens the breaker")
+	}
+	for i := 0; i < 5; i++ {
+		if err := publisher.Notify([]byte("routed")); err != nil {
+			t.Errorf("Notify() with fallback error = %v", err)
+		}
+	}
+	if got := primary.GetEventCount(); got != 1 {
+		t.Errorf("primary processed %d events, want 1", got)
+	}
+	if got := fallback.GetEventCount(); got != 5 {
+		t.Errorf("fallback processed %d events, want 5", got)
+	}
+	stats, _ := publisher.GetSubscriptionStats("primary")
+	if want := (node.SubscriptionStats{Failed: 1, Fallback: 5}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
@@ -0,0 +1,251 @@
+package node_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeDeadLetterReasons(t *testing.T) {
+	errDown := errors.New("enricher down")
+	publisher := node.NewBaseNode("publisher")
+	sink := node.NewRingSink(10)
+	publisher.SetDeadLetterSink(sink)
+
+	var healthy int32
+	flaky := node.NewBaseNode("flaky")
+	flaky.SetProcessFunc(func(input []byte) ([]byte, error) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			return nil, fmt.Errorf("enrich: %w", errDown)
+		}
+		return input, nil
+	})
+	typed := node.NewTypedNode("typed", node.JSONCodec{}, func(r reading) (reading, error) {
+		return r, nil
+	})
+	quarantined := node.NewBaseNode("quarantined")
+	quarantined.SetPanicPolicy(node.PanicPolicy{Action: node.PanicQuarantine})
+	quarantined.SetProcessFunc(func([]byte) ([]byte, error) { panic("bad plugin") })
+	createNodes(t, publisher, flaky, typed, quarantined)
+	quarantined.Process(nil)
+
+	if err := publisher.Subscribe(flaky, node.WithRetry(node.RetryPolicy{MaxAttempts: 3})); err != nil {
+		t.Fatalf("Subscribe(flaky) error = %v", err)
+	}
+	for _, sub := range []node.Node{typed, quarantined} {
+		if err := publisher.Subscribe(sub); err != nil {
+			t.Fatalf("Subscribe(%s) error = %v", sub.GetID(), err)
+		}
+	}
+
+	if err := publisher.Notify([]byte("not json")); err == nil {
+		t.Fatal("Notify() error = nil, want delivery failures")
+	}
+
+	records := sink.Records()
+	if len(records) != 3 {
+		t.Fatalf("dead letters = %d, want 3", len(records))
+	}
+	want := map[string]struct {
+		reason   node.DeadLetterReason
+		attempts int
+	}{
+		"flaky":       {node.ReasonRetriesExhausted, 3},
+		"typed":       {node.ReasonMalformed, 1},
+		"quarantined": {node.ReasonQuarantined, 1},
+	}
+	for _, dl := range records {
+		w, ok := want[dl.SubscriberID]
+		if !ok {
+			t.Errorf("unexpected dead letter for %s", dl.SubscriberID)
+			continue
+		}
+		if dl.Reason != w.reason || dl.Attempts != w.attempts {
+			t.Errorf("%s: reason = %s, attempts = %d, want %s, %d", dl.SubscriberID, dl.Reason, dl.Attempts, w.reason, w.attempts)
+		}
+		if dl.PublisherID != "publisher" || string(dl.Message.Payload) != "not json" {
+			t.Errorf("%s: dead letter = %+v", dl.SubscriberID, dl)
+		}
+		if dl.SubscriberID == "flaky" {
+			chain := dl.ErrorChain()
+			if len(chain) != 2 || chain[1] != errDown.Error() || !errors.Is(dl.Err, errDown) {
+				t.Errorf("flaky: error chain = %q", chain)
+			}
+		}
+	}
+
+	// Fix the subscriber and redrive only its dead letters.
+	atomic.StoreInt32(&healthy, 1)
+	before := flaky.GetEventCount()
+	for _, dl := range sink.Drain() {
+		if dl.SubscriberID != "flaky" {
+			continue
+		}
+		if err := publisher.Redrive(dl); err != nil {
+			t.Errorf("Redrive() error = %v", err)
+		}
+	}
+	if got := flaky.GetEventCount() - before; got != 1 {
+		t.Errorf("flaky received %d redriven events, want 1", got)
+	}
+	if got := len(sink.Records()); got != 0 {
+		t.Errorf("dead letters after successful redrive = %d, want 0", got)
+	}
+
+	if err := publisher.Redrive(node.DeadLetter{Message: node.NewMessage("x", nil), SubscriberID: "missing"}); err == nil {
+		t.Error("Redrive() to unknown subscriber error = nil, want error")
+	}
+	if err := publisher.Redrive(node.DeadLetter{SubscriberID: "flaky"}); err == nil {
+		t.Error("Redrive() without a message error = nil, want error")
+	}
+	if err := publisher.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := publisher.Redrive(node.DeadLetter{Message: node.NewMessage("x", nil), SubscriberID: "flaky"}); !errors.Is(err, node.ErrNotRunning) {
+		t.Errorf("Redrive() on deleted publisher error = %v, want ErrNotRunning", err)
+	}
+}
+
+func newRejectingNode(id string) *node.BaseNode {
+	n := node.NewBaseNode(id)
+	n.SetProcessFunc(func([]byte) ([]byte, error) {
+		return nil, node.Permanent(errors.New("rejected"))
+	})
+	n.Create()
+	return n
+}
+
+func TestDeadLetterSinks(t *testing.T) {
+	t.Run("ring overflow", func(t *testing.T) {
+		ring := node.NewRingSink(2)
+		for i := 0; i < 5; i++ {
+			ring.Put(node.DeadLetter{SubscriberID: fmt.Sprint(i)})
+		}
+		records := ring.Records()
+		if len(records) != 2 || records[0].SubscriberID != "3" || records[1].SubscriberID != "4" {
+			t.Errorf("Records() = %+v, want the two newest", records)
+		}
+		if ring.Dropped() != 3 {
+			t.Errorf("Dropped() = %d, want 3", ring.Dropped())
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+		fileSink, err := node.OpenFileSink(path)
+		if err != nil {
+			t.Fatalf("OpenFileSink() error = %v", err)
+		}
+		publisher := node.NewBaseNode("publisher")
+		failing := newRejectingNode("failing")
+		createNodes(t, publisher)
+		publisher.SetDeadLetterSink(fileSink)
+		if err := publisher.Subscribe(failing); err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			publisher.Notify([]byte(fmt.Sprintf("event-%d", i)))
+		}
+		if err := fileSink.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		records, err := node.ReadDeadLetters(path)
+		if err != nil {
+			t.Fatalf("ReadDeadLetters() error = %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("ReadDeadLetters() returned %d records, want 3", len(records))
+		}
+		for i, dl := range records {
+			if string(dl.Message.Payload) != fmt.Sprintf("event-%d", i) || dl.Reason != node.ReasonRejected {
+				t.Errorf("record %d = %+v", i, dl)
+			}
+			if dl.Err == nil || dl.Err.Error() != "rejected" || dl.Time.IsZero() {
+				t.Errorf("record %d error = %v, time = %v", i, dl.Err, dl.Time)
+			}
+		}
+
+		failing.SetProcessFunc(func(input []byte) ([]byte, error) { return input, nil })
+		publisher.SetDeadLetterSink(nil)
+		if err := publisher.RedriveAll(records); err != nil {
+			t.Errorf("RedriveAll() error = %v", err)
+		}
+	})
+
+	t.Run("node", func(t *testing.T) {
+		dlq := node.NewBaseNode("dlq")
+		var received *node.Message
+		dlq.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+			received = msg
+			return msg, nil
+		})
+		publisher := node.NewBaseNode("publisher")
+		createNodes(t, dlq, publisher)
+		publisher.SetDeadLetterSink(node.NewNodeSink(dlq))
+		if err := publisher.Subscribe(newRejectingNode("failing")); err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		publisher.Notify([]byte("event"))
+
+		if received == nil {
+			t.Fatal("dead-letter node received nothing")
+		}
+		if received.Header("dead-letter-reason") != string(node.ReasonRejected) {
+			t.Errorf("dead-letter-reason header = %q", received.Header("dead-letter-reason"))
+		}
+		var dl node.DeadLetter
+		if err := json.Unmarshal(received.Payload, &dl); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if dl.SubscriberID != "failing" || string(dl.Message.Payload) != "event" {
+			t.Errorf("decoded dead letter = %+v", dl)
+		}
+		if time.Since(dl.Time) > time.Minute {
+			t.Errorf("dead letter time = %v", dl.Time)
+		}
+	})
+}
+
+func TestBaseNodeDeleteWaitsForRedrive(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	slow := node.NewBaseNode("slow")
+	createNodes(t, publisher, slow)
+	started := make(chan struct{})
+	var finished int32
+	slow.SetProcessFunc(func(input []byte) ([]byte, error) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+		return input, nil
+	})
+	if err := publisher.Subscribe(slow); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	redriven := make(chan error, 1)
+	go func() {
+		redriven <- publisher.Redrive(node.DeadLetter{Message: node.NewMessage("publisher", []byte("event")), SubscriberID: "slow"})
+	}()
+	<-started
+	report, err := publisher.DeleteContext(context.Background())
+	if err != nil {
+		t.Fatalf("DeleteContext() error = %v", err)
+	}
+	if atomic.LoadInt32(&finished) == 0 {
+		t.Error("DeleteContext() returned before the redrive finished")
+	}
+	if err := <-redriven; err != nil {
+		t.Errorf("Redrive() error = %v", err)
+	}
+	if report.Drained != 1 {
+		t.Errorf("report = %+v, want the redrive drained", report)
+	}
+}
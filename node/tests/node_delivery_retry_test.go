@@ -0,0 +1,128 @@
+package node_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+type throttledError struct{ retry bool }
+
+func (e throttledError) Error() string   { return "throttled" }
+func (e throttledError) Retryable() bool { return e.retry }
+
+func TestBaseNodeDeliveryRetries(t *testing.T) {
+	errTransient := errors.New("transient")
+	policy := node.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond}
+
+	tests := []struct {
+		name         string
+		failures     int
+		err          error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"recovers after transient failures", 2, errTransient, 3, false},
+		{"gives up after max attempts", 10, errTransient, 4, true},
+		{"does not retry permanent errors", 10, node.Permanent(errTransient), 1, true},
+		{"honours Retryable() false", 10, throttledError{retry: false}, 1, true},
+		{"honours Retryable() true", 1, throttledError{retry: true}, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := node.NewBaseNode("publisher")
+			subscriber := node.NewBaseNode("subscriber")
+			createNodes(t, publisher, subscriber)
+			var calls int32
+			subscriber.SetProcessFunc(func(input []byte) ([]byte, error) {
+				if int(atomic.AddInt32(&calls, 1)) <= tt.failures {
+					return nil, tt.err
+				}
+				return input, nil
+			})
+			if err := publisher.Subscribe(subscriber, node.WithRetry(policy)); err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+
+			err := publisher.Notify([]byte("event"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := int(atomic.LoadInt32(&calls)); got != tt.wantAttempts {
+				t.Errorf("process calls = %d, want %d", got, tt.wantAttempts)
+			}
+			if err != nil {
+				var derr *node.DeliveryError
+				if !errors.As(err, &derr) {
+					t.Fatalf("Notify() error = %v, want *DeliveryError", err)
+				}
+				if derr.Attempts != tt.wantAttempts || derr.SubscriberID != "subscriber" {
+					t.Errorf("DeliveryError = %+v, want %d attempts to subscriber", derr, tt.wantAttempts)
+				}
+			}
+		})
+	}
+}
+
+func TestBaseNodeRetriesDoNotBlockOtherSubscribers(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	flaky := node.NewBaseNode("flaky")
+	fast := node.NewBaseNode("fast")
+	createNodes(t, publisher, flaky, fast)
+
+	flaky.SetProcessFunc(func([]byte) ([]byte, error) {
+		return nil, errors.New("unavailable")
+	})
+	start := time.Now()
+	var fastDelay int64
+	fast.SetProcessFunc(func(input []byte) ([]byte, error) {
+		atomic.StoreInt64(&fastDelay, int64(time.Since(start)))
+		return input, nil
+	})
+
+	retry := node.RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond}
+	if err := publisher.Subscribe(flaky, node.WithRetry(retry)); err != nil {
+		t.Fatalf("Subscribe(flaky) error = %v", err)
+	}
+	if err := publisher.Subscribe(fast); err != nil {
+		t.Fatalf("Subscribe(fast) error = %v", err)
+	}
+
+	if err := publisher.Notify([]byte("event")); err == nil {
+		t.Fatal("Notify() error = nil, want flaky delivery failure")
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Notify() returned after %v, want retries with backoff", elapsed)
+	}
+	if delay := time.Duration(atomic.LoadInt64(&fastDelay)); delay > 50*time.Millisecond {
+		t.Errorf("fast subscriber received event after %v, want it delivered without waiting for retries", delay)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := node.RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(1); got < 5*time.Millisecond || got > 15*time.Millisecond {
+			t.Fatalf("Backoff(1) with jitter = %v, want within [5ms, 15ms]", got)
+		}
+	}
+
+	if node.IsRetryable(&node.DecodeError{Codec: "json", Err: errors.New("bad")}) {
+		t.Error("IsRetryable(*DecodeError) = true, want false")
+	}
+	if node.IsRetryable(node.ErrQuarantined) {
+		t.Error("IsRetryable(ErrQuarantined) = true, want false")
+	}
+}
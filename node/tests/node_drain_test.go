@@ -0,0 +1,175 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// waitForState polls until n reaches state or the test times out.
+func waitForState(t *testing.T, n *node.BaseNode, state node.State) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for n.GetState() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: state = %v, want %v", n.GetID(), n.GetState(), state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBaseNodeDeleteDrainsInFlightWork(t *testing.T) {
+	worker := node.NewBaseNode("worker")
+	sink := node.NewBaseNode("sink")
+	createNodes(t, worker, sink)
+	if err := worker.Subscribe(sink); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	worker.SetProcessFunc(func(input []byte) ([]byte, error) {
+		close(started)
+		<-release
+		return input, worker.Notify(input)
+	})
+
+	processed := make(chan error, 1)
+	go func() {
+		_, err := worker.Process([]byte("mid-flight"))
+		processed <- err
+	}()
+	<-started
+
+	type result struct {
+		report node.DrainReport
+		err    error
+	}
+	deleted := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		report, err := worker.DeleteContext(ctx)
+		deleted <- result{report, err}
+	}()
+
+	waitForState(t, worker, node.StateStopping)
+	if _, err := worker.Process([]byte("late")); !errors.Is(err, node.ErrNotRunning) {
+		t.Errorf("Process() while draining error = %v, want ErrNotRunning", err)
+	}
+	close(release)
+
+	if err := <-processed; err != nil {
+		t.Errorf("in-flight Process() error = %v", err)
+	}
+	res := <-deleted
+	if res.err != nil {
+		t.Fatalf("DeleteContext() error = %v", res.err)
+	}
+	// The Process call and its delivery to sink.
+	if len(res.report.Abandoned) != 0 || res.report.Drained != 2 {
+		t.Errorf("report = %+v, want 2 drained and nothing abandoned", res.report)
+	}
+	if sink.GetEventCount() != 1 {
+		t.Errorf("sink: GetEventCount() = %d, want the drained event forwarded", sink.GetEventCount())
+	}
+	if worker.GetState() != node.StateStopped {
+		t.Errorf("GetState() = %v, want stopped", worker.GetState())
+	}
+}
+
+func TestBaseNodeDeleteDrainsOutgoingDeliveries(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	slow := node.NewBaseNode("slow")
+	createNodes(t, publisher, slow)
+	slow.SetProcessFunc(func(input []byte) ([]byte, error) {
+		time.Sleep(50 * time.Millisecond)
+		return input, nil
+	})
+	if err := publisher.Subscribe(slow); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	notified := make(chan error, 1)
+	go func() { notified <- publisher.Notify([]byte("event")) }()
+	time.Sleep(10 * time.Millisecond)
+
+	report, err := publisher.DeleteContext(context.Background())
+	if err != nil {
+		t.Fatalf("DeleteContext() error = %v", err)
+	}
+	if slow.GetEventCount() != 1 {
+		t.Errorf("slow: GetEventCount() = %d, want delivery finished before Delete returned", slow.GetEventCount())
+	}
+	if report.Drained != 1 {
+		t.Errorf("report = %+v, want the delivery counted as drained once", report)
+	}
+	if err := <-notified; err != nil {
+		t.Errorf("Notify() error = %v", err)
+	}
+}
+
+func TestBaseNodeDeleteCutsRetriesShort(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	flaky := node.NewBaseNode("flaky")
+	createNodes(t, publisher, flaky)
+	flaky.SetProcessFunc(func([]byte) ([]byte, error) {
+		return nil, errors.New("unavailable")
+	})
+	retry := node.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+	if err := publisher.Subscribe(flaky, node.WithRetry(retry)); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	notified := make(chan error, 1)
+	go func() { notified <- publisher.Notify([]byte("event")) }()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := publisher.DeleteContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DeleteContext() error = %v, want DeadlineExceeded", err)
+	}
+	select {
+	case err := <-notified:
+		var de *node.DeliveryError
+		if !errors.As(err, &de) || de.Attempts != 1 {
+			t.Errorf("Notify() error = %v, want a failure after 1 attempt", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Notify() still retrying after the drain deadline")
+	}
+}
+
+func TestBaseNodeDeleteReportsAbandonedWork(t *testing.T) {
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	n.SetProcessFunc(func(input []byte) ([]byte, error) {
+		close(started)
+		<-release
+		return input, nil
+	})
+	go n.Process([]byte("stuck"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report, err := n.DeleteContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DeleteContext() error = %v, want DeadlineExceeded", err)
+	}
+	if len(report.Abandoned) != 1 {
+		t.Fatalf("Abandoned = %v, want 1 message", report.Abandoned)
+	}
+	abandoned := report.Abandoned[0]
+	if string(abandoned.Message.Payload) != "stuck" || abandoned.SubscriberID != "" {
+		t.Errorf("Abandoned[0] = %+v", abandoned)
+	}
+	if n.GetState() != node.StateStopped {
+		t.Errorf("GetState() = %v, want stopped", n.GetState())
+	}
+}
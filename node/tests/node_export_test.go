@@ -0,0 +1,90 @@
+package node_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// exportFixture builds source -> {ok, flaky}, runs some events through it
+// and pauses flaky.
+func exportFixture(t *testing.T) *node.BaseNode {
+	t.Helper()
+	source := node.NewBaseNode("source")
+	ok := node.NewBaseNode("ok")
+	flaky := node.NewBaseNode(`flaky "b"`)
+	createNodes(t, source, ok, flaky)
+
+	calls := 0
+	flaky.SetProcessFunc(func(input []byte) ([]byte, error) {
+		calls++
+		if calls%4 == 0 {
+			return nil, errors.New("flaked")
+		}
+		return input, nil
+	})
+	source.Subscribe(ok)
+	source.Subscribe(flaky)
+	for i := 0; i < 8; i++ {
+		source.Notify([]byte("event"))
+	}
+	flaky.Pause()
+	return source
+}
+
+func TestBaseNodeSubscribersAndStats(t *testing.T) {
+	source := exportFixture(t)
+	subs := source.GetSubscribers()
+	if len(subs) != 2 || subs[0].GetID() != `flaky "b"` || subs[1].GetID() != "ok" {
+		t.Fatalf("GetSubscribers() = %v", subs)
+	}
+	stats, ok := source.GetSubscriptionStats(`flaky "b"`)
+	if !ok || stats.Delivered != 6 || stats.Failed != 2 || stats.ErrorRate() != 0.25 {
+		t.Errorf("GetSubscriptionStats(flaky) = %+v, %v", stats, ok)
+	}
+	if _, ok := source.GetSubscriptionStats("missing"); ok {
+		t.Error("GetSubscriptionStats(missing) ok = true")
+	}
+}
+
+func TestExportDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := node.ExportDOT(&buf, exportFixture(t)); err != nil {
+		t.Fatalf("ExportDOT() error = %v", err)
+	}
+	dot := buf.String()
+	for _, want := range []string{
+		"digraph constellation {",
+		`"source" [label="source\nrunning", fillcolor="#d1e7dd"];`,
+		`"flaky \"b\"" [label="flaky \"b\"\npaused", fillcolor="#fff3cd"];`,
+		`"source" -> "ok" [label="8 events"];`,
+		`"source" -> "flaky \"b\"" [label="8 events, 25.0% errors", color=red];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %s:\n%s", want, dot)
+		}
+	}
+}
+
+func TestExportMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := node.ExportMermaid(&buf, exportFixture(t)); err != nil {
+		t.Fatalf("ExportMermaid() error = %v", err)
+	}
+	mermaid := buf.String()
+	for _, want := range []string{
+		"flowchart LR",
+		`n0["flaky #quot;b#quot;<br/>paused"]:::paused`,
+		`n2["source<br/>running"]:::running`,
+		`n2 -->|"8 events, 25.0% errors"| n0`,
+		`n2 -->|"8 events"| n1`,
+		"classDef paused fill:#fff3cd",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output missing %s:\n%s", want, mermaid)
+		}
+	}
+}
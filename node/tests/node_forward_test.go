@@ -0,0 +1,145 @@
+package node_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeForwarding(t *testing.T) {
+	source := node.NewBaseNode("source")
+	sink := newRecorder("sink")
+	createNodes(t, source, sink)
+	source.Subscribe(sink)
+	source.SetProcessFunc(func(input []byte) ([]byte, error) {
+		return bytes.TrimSpace(input), nil
+	})
+
+	if _, err := source.Process([]byte(" ignored ")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if got := sink.payloads(); len(got) != 0 {
+		t.Fatalf("sink received %q before forwarding was enabled", got)
+	}
+
+	source.SetForwarding(node.ForwardConfig{})
+	if cfg, ok := source.GetForwarding(); !ok || cfg.SkipEmpty {
+		t.Errorf("GetForwarding() = %+v, %v", cfg, ok)
+	}
+	out, err := source.Process([]byte(" event "))
+	if err != nil || string(out) != "event" {
+		t.Fatalf("Process() = %q, %v", out, err)
+	}
+	if got := sink.payloads(); len(got) != 1 || got[0] != "event" {
+		t.Errorf("sink received %q, want [event]", got)
+	}
+
+	boom := errors.New("boom")
+	sink.SetProcessFunc(func([]byte) ([]byte, error) { return nil, boom })
+	if out, err := source.Process([]byte("x")); string(out) != "x" || !errors.Is(err, boom) {
+		t.Errorf("Process() = %q, %v, want the output and the delivery error", out, err)
+	}
+
+	source.DisableForwarding()
+	if _, err := source.Process([]byte("y")); err != nil {
+		t.Errorf("Process() after DisableForwarding error = %v", err)
+	}
+}
+
+func TestBaseNodeForwardingSkipEmptyAndSplit(t *testing.T) {
+	source := node.NewBaseNode("source")
+	sink := newRecorder("sink")
+	createNodes(t, source, sink)
+	source.Subscribe(sink)
+	source.SetForwarding(node.ForwardConfig{
+		SkipEmpty: true,
+		Split: func(payload []byte) [][]byte {
+			return bytes.Split(payload, []byte(","))
+		},
+	})
+
+	for _, event := range []string{"a,b", "", "c,,d"} {
+		if _, err := source.Process([]byte(event)); err != nil {
+			t.Fatalf("Process(%q) error = %v", event, err)
+		}
+	}
+	if got := strings.Join(sink.payloads(), " "); got != "a b c d" {
+		t.Errorf("sink received %q, want a b c d", got)
+	}
+}
+
+func TestBaseNodeForwardingNoEcho(t *testing.T) {
+	a, b := node.NewBaseNode("a"), node.NewBaseNode("b")
+	createNodes(t, a, b)
+	a.Subscribe(b)
+	b.Subscribe(a)
+	a.SetForwarding(node.ForwardConfig{NoEcho: true})
+	b.SetForwarding(node.ForwardConfig{NoEcho: true})
+
+	if _, err := a.Process([]byte("ping")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if a.GetEventCount() != 1 || b.GetEventCount() != 1 {
+		t.Errorf("event counts = %d, %d, want 1 and 1", a.GetEventCount(), b.GetEventCount())
+	}
+}
+
+func TestBaseNodeForwardingPreventLoops(t *testing.T) {
+	a, b, c := node.NewBaseNode("a"), node.NewBaseNode("b"), node.NewBaseNode("c")
+	createNodes(t, a, b, c)
+	a.Subscribe(b)
+	b.Subscribe(c)
+	c.Subscribe(a)
+	var route string
+	a.Use(func(next node.MessageFunc) node.MessageFunc {
+		return func(msg *node.Message) (*node.Message, error) {
+			route = msg.Header(node.HeaderRoute)
+			return next(msg)
+		}
+	})
+	for _, n := range []*node.BaseNode{a, b, c} {
+		n.SetForwarding(node.ForwardConfig{PreventLoops: true})
+	}
+
+	if _, err := a.Process([]byte("event")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if a.GetEventCount() != 2 || b.GetEventCount() != 1 || c.GetEventCount() != 1 {
+		t.Errorf("event counts = %d, %d, %d, want 2, 1, 1", a.GetEventCount(), b.GetEventCount(), c.GetEventCount())
+	}
+	if route != "a,b,c" {
+		t.Errorf("route seen by a = %q, want a,b,c", route)
+	}
+}
+
+func TestBaseNodeForwardingBatch(t *testing.T) {
+	source := node.NewBaseNode("source")
+	sink := newRecorder("sink")
+	createNodes(t, source, sink)
+	source.Subscribe(sink)
+	source.SetForwarding(node.ForwardConfig{SkipEmpty: true})
+	source.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		var outs []*node.Message
+		for _, part := range strings.Fields(string(msg.Payload)) {
+			outs = append(outs, msg.Derive([]byte(part)))
+		}
+		return node.NewBatch(outs...), nil
+	})
+
+	out, err := source.ProcessMessage(node.NewMessage("", []byte("a b c")))
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if batch := out.Batch(); len(batch) != 3 || string(batch[2].Payload) != "c" {
+		t.Errorf("Batch() = %v, want 3 messages", batch)
+	}
+	if _, err := source.Process(nil); err != nil {
+		t.Errorf("Process() with an empty batch error = %v", err)
+	}
+	if got := strings.Join(sink.payloads(), ","); got != "a,b,c" {
+		t.Errorf("sink received %q, want each message of the batch once", got)
+	}
+}
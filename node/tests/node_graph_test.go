@@ -0,0 +1,213 @@
+package node_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestAnalyzeGraph(t *testing.T) {
+	nodes := make(map[string]*node.BaseNode)
+	var all []node.Node
+	for _, id := range []string{"hub", "s1", "s2", "s3", "x", "y", "z", "self", "lonely"} {
+		nodes[id] = node.NewBaseNode(id)
+		all = append(all, nodes[id])
+	}
+	subscribe := func(from, to string) {
+		t.Helper()
+		if err := nodes[from].Subscribe(nodes[to]); err != nil {
+			t.Fatalf("Subscribe(%s -> %s) error = %v", from, to, err)
+		}
+	}
+	subscribe("hub", "s1")
+	subscribe("hub", "s2")
+	subscribe("hub", "s3")
+	subscribe("s1", "s2")
+	subscribe("x", "y")
+	subscribe("y", "z")
+	subscribe("z", "x")
+	subscribe("self", "self")
+
+	report := node.AnalyzeGraph(all, node.GraphOptions{FanOutThreshold: 3})
+	if !reflect.DeepEqual(report.Cycles, [][]string{{"x", "y", "z"}}) {
+		t.Errorf("Cycles = %v", report.Cycles)
+	}
+	if !reflect.DeepEqual(report.SelfSubscriptions, []string{"self"}) {
+		t.Errorf("SelfSubscriptions = %v", report.SelfSubscriptions)
+	}
+	if !reflect.DeepEqual(report.Unreachable, []string{"x", "y", "z"}) {
+		t.Errorf("Unreachable = %v", report.Unreachable)
+	}
+	if !reflect.DeepEqual(report.Isolated, []string{"lonely"}) {
+		t.Errorf("Isolated = %v", report.Isolated)
+	}
+	if !reflect.DeepEqual(report.Hotspots, []node.Hotspot{{ID: "hub", Subscribers: 3}}) {
+		t.Errorf("Hotspots = %v", report.Hotspots)
+	}
+	if !report.HasCycles() {
+		t.Error("HasCycles() = false")
+	}
+
+	// Explicit roots make everything outside their reach unreachable.
+	report = node.AnalyzeGraph([]node.Node{nodes["hub"]}, node.GraphOptions{Roots: []string{"s1"}})
+	if !reflect.DeepEqual(report.Unreachable, []string{"hub", "s3"}) || report.HasCycles() {
+		t.Errorf("rooted at s1: Unreachable = %v, HasCycles = %v", report.Unreachable, report.HasCycles())
+	}
+	if len(report.Nodes) != 4 {
+		t.Errorf("Nodes = %v, want hub and the subscribers it reaches", report.Nodes)
+	}
+}
+
+func TestBaseNodeCycleCheck(t *testing.T) {
+	a := node.NewBaseNode("a")
+	b := node.NewBaseNode("b")
+	c := node.NewBaseNode("c")
+	for _, n := range []*node.BaseNode{a, b, c} {
+		n.SetCycleCheck(true)
+	}
+	if err := a.Subscribe(b); err != nil {
+		t.Fatalf("Subscribe(a -> b) error = %v", err)
+	}
+	if err := b.Subscribe(c); err != nil {
+		t.Fatalf("Subscribe(b -> c) error = %v", err)
+	}
+	if err := c.Subscribe(a); !errors.Is(err, node.ErrCycle) {
+		t.Errorf("Subscribe(c -> a) error = %v, want ErrCycle", err)
+	}
+	if err := a.Subscribe(a); !errors.Is(err, node.ErrCycle) {
+		t.Errorf("Subscribe(a -> a) error = %v, want ErrCycle", err)
+	}
+	if err := a.Subscribe(c); err != nil {
+		t.Errorf("Subscribe(a -> c) error = %v, want shortcut allowed", err)
+	}
+	if c.GetSubscription("a") != nil || a.GetSubscription("a") != nil {
+		t.Error("refused subscription was added")
+	}
+
+	c.SetCycleCheck(false)
+	if err := c.Subscribe(a); err != nil {
+		t.Errorf("Subscribe(c -> a) with check disabled error = %v", err)
+	}
+}
+
+func TestBaseNodeMaxHops(t *testing.T) {
+	a := node.NewBaseNode("a")
+	b := node.NewBaseNode("b")
+	createNodes(t, a, b)
+	sink := node.NewRingSink(10)
+	b.SetDeadLetterSink(sink)
+
+	var maxSeen int
+	for _, n := range []*node.BaseNode{a, b} {
+		n := n
+		n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+			if msg.Hops > maxSeen {
+				maxSeen = msg.Hops
+			}
+			out := msg.Derive(msg.Payload)
+			return out, n.NotifyMessage(out)
+		})
+	}
+	a.Subscribe(b)
+	b.Subscribe(a)
+	a.SetMaxHops(5)
+
+	if err := a.Notify([]byte("storm")); !errors.Is(err, node.ErrTTLExceeded) {
+		t.Fatalf("Notify() error = %v, want ErrTTLExceeded", err)
+	}
+	if a.GetEventCount() != 3 || b.GetEventCount() != 3 || maxSeen != 6 {
+		t.Errorf("a = %d, b = %d events, max hops = %d, want 3, 3, 6", a.GetEventCount(), b.GetEventCount(), maxSeen)
+	}
+	// The refused delivery and the deliveries that led to it are
+	// dead-lettered without retries.
+	records := sink.Records()
+	if len(records) != 3 {
+		t.Fatalf("got %d dead letters, want 3", len(records))
+	}
+	for _, dl := range records {
+		if dl.Reason != node.ReasonTTLExceeded || dl.Attempts != 1 {
+			t.Errorf("dead letter reason = %s, attempts = %d, want ttl_exceeded, 1", dl.Reason, dl.Attempts)
+		}
+	}
+	if records[0].Message.Hops != 6 {
+		t.Errorf("first dead letter Hops = %d, want 6", records[0].Message.Hops)
+	}
+}
+
+func TestBaseNodeMaxHopsWithForwarding(t *testing.T) {
+	a := node.NewBaseNode("a")
+	b := node.NewBaseNode("b")
+	createNodes(t, a, b)
+	for _, n := range []*node.BaseNode{a, b} {
+		n := n
+		n.SetForwarding(node.ForwardConfig{})
+		// Output built from scratch still carries the input's hops.
+		n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+			return node.NewMessage(n.GetID(), msg.Payload), nil
+		})
+	}
+	a.Subscribe(b)
+	b.Subscribe(a)
+	a.SetMaxHops(5)
+
+	done := make(chan error, 1)
+	go func() { done <- a.Notify([]byte("ping")) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, node.ErrTTLExceeded) {
+			t.Fatalf("Notify() error = %v, want ErrTTLExceeded", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("forwarding ping-pong did not stop at the hop limit")
+	}
+	if a.GetEventCount() != 3 || b.GetEventCount() != 3 {
+		t.Errorf("a = %d, b = %d events, want 3, 3", a.GetEventCount(), b.GetEventCount())
+	}
+}
+
+func TestBaseNodeNotifyStartsAtZeroHops(t *testing.T) {
+	a := node.NewBaseNode("a")
+	b := node.NewBaseNode("b")
+	createNodes(t, a, b)
+	a.Subscribe(b)
+	a.SetMaxHops(2)
+
+	release := make(chan struct{})
+	processing := make(chan struct{})
+	var hops []int
+	var mu sync.Mutex
+	b.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		mu.Lock()
+		hops = append(hops, msg.Hops)
+		mu.Unlock()
+		if msg.Hops > 1 {
+			close(processing)
+			<-release
+		}
+		return msg, nil
+	})
+
+	// A message near the limit is still being processed when an unrelated
+	// event is published.
+	old := node.NewMessage("a", []byte("old"))
+	old.Hops = 1
+	done := make(chan error, 1)
+	go func() { done <- a.NotifyMessage(old) }()
+	<-processing
+	if err := a.Notify([]byte("new")); err != nil {
+		t.Errorf("Notify() error = %v, want nil", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("NotifyMessage() error = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hops) != 2 || hops[0] != 2 || hops[1] != 1 {
+		t.Errorf("hops = %v, want [2 1]", hops)
+	}
+}
@@ -0,0 +1,162 @@
+package node_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// countByKey counts events per account in the node's key-value store.
+func countByKey(kv *node.KV, msg *node.Message) (*node.Message, error) {
+	account := accountKey(msg)
+	scope := kv.Scope(account)
+	v, _, err := scope.Get("count")
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(string(v))
+	n++
+	if err := scope.Put("count", []byte(strconv.Itoa(n))); err != nil {
+		return nil, err
+	}
+	return msg.Derive([]byte(account + "=" + strconv.Itoa(n))), nil
+}
+
+func rangeKeys(t *testing.T, kv *node.KV, start, end string) []string {
+	t.Helper()
+	var keys []string
+	err := kv.Range(start, end, func(key string, value []byte) bool {
+		keys = append(keys, strings.ReplaceAll(key, "\x00", "/")+"="+string(value))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	return keys
+}
+
+func TestBaseNodeKV(t *testing.T) {
+	counter := node.NewBaseNode("counter")
+	counter.SetKVFunc(countByKey)
+	createNodes(t, counter)
+
+	for _, event := range []string{"a:1", "b:1", "a:2"} {
+		if _, err := counter.Process([]byte(event)); err != nil {
+			t.Fatalf("Process(%s) error = %v", event, err)
+		}
+	}
+	out, err := counter.Process([]byte("a:3"))
+	if err != nil || string(out) != "a=3" {
+		t.Errorf("Process() = %q, %v, want a=3", out, err)
+	}
+
+	kv := counter.KV()
+	if got := strings.Join(rangeKeys(t, kv, "", ""), ","); got != "a/count=3,b/count=1" {
+		t.Errorf("Range() = %s", got)
+	}
+	if got := strings.Join(rangeKeys(t, kv, "b", ""), ","); got != "b/count=1" {
+		t.Errorf("Range(b) = %s", got)
+	}
+	if err := kv.Scope("a").Delete("count"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := kv.Scope("a").Get("count"); ok {
+		t.Error("Get() found a deleted key")
+	}
+}
+
+func TestKVIsScopedPerNode(t *testing.T) {
+	store := node.NewMemoryStore()
+	a, b := node.NewBaseNode("a"), node.NewBaseNode("ab")
+	a.SetKVStore(store)
+	b.SetKVStore(store)
+	a.KV().Put("k", []byte("from a"))
+	b.KV().Put("k", []byte("from ab"))
+
+	if got := strings.Join(rangeKeys(t, a.KV(), "", ""), ","); got != "k=from a" {
+		t.Errorf("a sees %s", got)
+	}
+	if v, _, _ := b.KV().Get("k"); string(v) != "from ab" {
+		t.Errorf("b Get(k) = %q", v)
+	}
+
+	var snapshot bytes.Buffer
+	if err := a.KV().Snapshot(&snapshot); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	restored := node.NewKV(node.NewMemoryStore())
+	if err := restored.Restore(&snapshot); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if v, ok, _ := restored.Get("k"); !ok || string(v) != "from a" {
+		t.Errorf("restored Get(k) = %q, %v", v, ok)
+	}
+}
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	store, err := node.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	counter := node.NewBaseNode("counter")
+	counter.SetKVStore(store)
+	counter.SetKVFunc(countByKey)
+	createNodes(t, counter)
+	for i := 0; i < 3; i++ {
+		counter.Process([]byte("a:x"))
+	}
+	counter.KV().Put("tmp", []byte("gone"))
+	counter.KV().Delete("tmp")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A record torn by a crash is dropped on open.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.Write([]byte{0xde, 0xad, 0xbe})
+	f.Close()
+
+	store, err = node.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer store.Close()
+	restarted := node.NewBaseNode("counter")
+	restarted.SetKVStore(store)
+	restarted.SetKVFunc(countByKey)
+	createNodes(t, restarted)
+	if out, err := restarted.Process([]byte("a:x")); err != nil || string(out) != "a=4" {
+		t.Errorf("Process() after restart = %q, %v, want a=4", out, err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", store.Len())
+	}
+
+	before, _ := os.Stat(path)
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("log size %d after Compact, want less than %d", after.Size(), before.Size())
+	}
+	store.Put("after", []byte("compact"))
+	store.Close()
+	store, err = node.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer store.Close()
+	if v, _, _ := node.NewKV(store).Scope("counter").Scope("a").Get("count"); string(v) != "4" {
+		t.Errorf("count after compaction = %q, want 4", v)
+	}
+	if v, _, _ := store.Get("after"); string(v) != "compact" {
+		t.Errorf("Get(after) = %q", v)
+	}
+}
@@ -0,0 +1,128 @@
+package node_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeLifecycle(t *testing.T) {
+	n := node.NewBaseNode("node")
+	if n.GetState() != node.StateNew {
+		t.Fatalf("GetState() = %v, want new", n.GetState())
+	}
+	if _, err := n.Process([]byte("early")); !errors.Is(err, node.ErrNotRunning) {
+		t.Errorf("Process() before Create error = %v, want ErrNotRunning", err)
+	}
+	if err := n.Notify([]byte("early")); !errors.Is(err, node.ErrNotRunning) {
+		t.Errorf("Notify() before Create error = %v, want ErrNotRunning", err)
+	}
+
+	var trace []string
+	n.OnStart(func() error { trace = append(trace, "start 1"); return nil })
+	n.OnStart(func() error { trace = append(trace, "start 2"); return nil })
+	n.OnStop(func() error { trace = append(trace, "stop 1"); return nil })
+	n.OnStop(func() error { trace = append(trace, "stop 2"); return nil })
+
+	if err := n.Create(); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if n.GetState() != node.StateRunning {
+		t.Fatalf("GetState() after Create = %v, want running", n.GetState())
+	}
+	if err := n.Create(); !errors.Is(err, node.ErrInvalidTransition) {
+		t.Errorf("second Create() error = %v, want ErrInvalidTransition", err)
+	}
+	if _, err := n.Process([]byte("event")); err != nil {
+		t.Errorf("Process() while running error = %v", err)
+	}
+
+	if err := n.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if n.GetState() != node.StateStopped {
+		t.Fatalf("GetState() after Delete = %v, want stopped", n.GetState())
+	}
+	if got := strings.Join(trace, ","); got != "start 1,start 2,stop 2,stop 1" {
+		t.Errorf("hook order = %s", got)
+	}
+	if _, err := n.Process([]byte("late")); !errors.Is(err, node.ErrNotRunning) {
+		t.Errorf("Process() after Delete error = %v, want ErrNotRunning", err)
+	}
+	if err := n.Delete(); !errors.Is(err, node.ErrInvalidTransition) {
+		t.Errorf("second Delete() error = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestBaseNodeLifecycleHookFailures(t *testing.T) {
+	errNoConfig := errors.New("missing config")
+	n := node.NewBaseNode("node")
+	var secondRan bool
+	n.OnStart(func() error { return errNoConfig })
+	n.OnStart(func() error { secondRan = true; return nil })
+
+	if err := n.Create(); !errors.Is(err, errNoConfig) {
+		t.Fatalf("Create() error = %v, want %v", err, errNoConfig)
+	}
+	if secondRan {
+		t.Error("start hook ran after an earlier hook failed")
+	}
+	if n.GetState() != node.StateFailed {
+		t.Fatalf("GetState() = %v, want failed", n.GetState())
+	}
+	if _, err := n.Process([]byte("event")); !errors.Is(err, node.ErrNotRunning) {
+		t.Errorf("Process() on failed node error = %v, want ErrNotRunning", err)
+	}
+
+	errFlush := errors.New("flush failed")
+	var cleanedUp bool
+	n.OnStop(func() error { cleanedUp = true; return nil })
+	n.OnStop(func() error { return errFlush })
+	if err := n.Delete(); !errors.Is(err, errFlush) {
+		t.Errorf("Delete() error = %v, want %v", err, errFlush)
+	}
+	if !cleanedUp {
+		t.Error("stop hook skipped after a later-registered hook failed")
+	}
+	if n.GetState() != node.StateFailed {
+		t.Errorf("GetState() after failed Delete = %v, want failed", n.GetState())
+	}
+}
+
+func TestBaseNodeDeleteDetachesFromPublishers(t *testing.T) {
+	type wrapped struct{ *node.BaseNode }
+
+	a := node.NewBaseNode("a")
+	b := node.NewBaseNode("b")
+	leaving := &wrapped{node.NewBaseNode("leaving")}
+	staying := node.NewBaseNode("staying")
+	createNodes(t, a, b, leaving, staying)
+
+	for _, publisher := range []*node.BaseNode{a, b} {
+		for _, sub := range []node.Node{leaving, staying} {
+			if err := publisher.Subscribe(sub); err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+		}
+	}
+
+	if err := leaving.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	for _, publisher := range []*node.BaseNode{a, b} {
+		if publisher.GetSubscription("leaving") != nil {
+			t.Errorf("%s still subscribed to deleted node", publisher.GetID())
+		}
+		if publisher.GetSubscription("staying") == nil {
+			t.Errorf("%s lost its subscription to staying", publisher.GetID())
+		}
+		if err := publisher.Notify([]byte("event")); err != nil {
+			t.Errorf("%s: Notify() after subscriber deletion error = %v", publisher.GetID(), err)
+		}
+	}
+	if staying.GetEventCount() != 2 {
+		t.Errorf("staying: GetEventCount() = %d, want 2", staying.GetEventCount())
+	}
+}
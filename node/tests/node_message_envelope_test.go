@@ -0,0 +1,109 @@
+package node_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// payloadNode implements only the Node interface, without message support.
+type payloadNode struct {
+	*node.BaseNode
+	mu       sync.Mutex
+	payloads []string
+}
+
+func (p *payloadNode) Process(input []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.payloads = append(p.payloads, string(input))
+	return input, nil
+}
+
+func TestBaseNodeMessageEnvelope(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	receiver := node.NewBaseNode("receiver")
+	legacy := node.NewBaseNode("legacy")
+	plain := &payloadNode{BaseNode: node.NewBaseNode("plain")}
+	createNodes(t, publisher, receiver, legacy, plain)
+
+	var mu sync.Mutex
+	var received []*node.Message
+	receiver.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, msg)
+		return msg, nil
+	})
+
+	var legacyInput string
+	legacy.SetProcessFunc(func(input []byte) ([]byte, error) {
+		legacyInput = string(input)
+		return []byte("legacy: " + string(input)), nil
+	})
+
+	for _, sub := range []node.Node{receiver, legacy, struct{ node.Node }{plain}} {
+		if err := publisher.Subscribe(sub); err != nil {
+			t.Fatalf("Subscribe(%s) error = %v", sub.GetID(), err)
+		}
+	}
+
+	before := time.Now()
+	msg := node.NewMessage("ignored", []byte(`{"temp": 31}`))
+	msg.ContentType = "application/json"
+	msg.SetHeader(node.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err := publisher.NotifyMessage(msg); err != nil {
+		t.Fatalf("NotifyMessage() error = %v", err)
+	}
+	if err := publisher.Notify([]byte("second")); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("receiver got %d messages, want 2", len(received))
+	}
+	first := received[0]
+	if first.Source != "publisher" {
+		t.Errorf("Source = %q, want publisher", first.Source)
+	}
+	if first.ID != msg.ID {
+		t.Errorf("ID = %q, want %q", first.ID, msg.ID)
+	}
+	if first.ContentType != "application/json" {
+		t.Errorf("ContentType = %q, want application/json", first.ContentType)
+	}
+	if first.Header(node.HeaderTraceParent) != msg.Header(node.HeaderTraceParent) {
+		t.Errorf("traceparent header not propagated: %q", first.Header(node.HeaderTraceParent))
+	}
+	if msg.Source != "ignored" {
+		t.Errorf("NotifyMessage() modified the caller's message: Source = %q", msg.Source)
+	}
+
+	second := received[1]
+	if second.Source != "publisher" || second.ID == "" || second.ID == first.ID {
+		t.Errorf("Notify() message = %+v, want fresh ID from publisher", second)
+	}
+	if second.Timestamp.Before(before) {
+		t.Errorf("Timestamp = %v, want after %v", second.Timestamp, before)
+	}
+
+	if legacyInput != "second" {
+		t.Errorf("legacy process func input = %q, want second", legacyInput)
+	}
+	if len(plain.payloads) != 2 || plain.payloads[1] != "second" {
+		t.Errorf("plain node payloads = %v", plain.payloads)
+	}
+
+	out, err := legacy.ProcessMessage(msg)
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if string(out.Payload) != `legacy: {"temp": 31}` {
+		t.Errorf("ProcessMessage() payload = %q", out.Payload)
+	}
+	if out.ID == msg.ID || out.Header(node.HeaderTraceParent) != msg.Header(node.HeaderTraceParent) {
+		t.Errorf("ProcessMessage() output = %+v, want derived message keeping headers", out)
+	}
+}
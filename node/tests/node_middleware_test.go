@@ -0,0 +1,114 @@
+package node_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeMiddlewareChain(t *testing.T) {
+	n := node.NewBaseNode("node")
+	if err := n.Create(); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	var trace []string
+	tracing := func(name string) node.Middleware {
+		return func(next node.MessageFunc) node.MessageFunc {
+			return func(msg *node.Message) (*node.Message, error) {
+				trace = append(trace, name+" before")
+				out, err := next(msg)
+				trace = append(trace, name+" after")
+				return out, err
+			}
+		}
+	}
+
+	n.Use(tracing("outer"), tracing("middle"))
+	n.Use(tracing("inner"))
+	n.SetProcessFunc(func(input []byte) ([]byte, error) {
+		trace = append(trace, "process")
+		return bytes.ToUpper(input), nil
+	})
+
+	out, err := n.Process([]byte("hello"))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if string(out) != "HELLO" {
+		t.Errorf("Process() output = %q, want HELLO", out)
+	}
+	want := "outer before,middle before,inner before,process,inner after,middle after,outer after"
+	if got := strings.Join(trace, ","); got != want {
+		t.Errorf("middleware order = %s, want %s", got, want)
+	}
+
+	n.ResetMiddleware()
+	trace = nil
+	if _, err := n.Process([]byte("hello")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if got := strings.Join(trace, ","); got != "process" {
+		t.Errorf("after ResetMiddleware trace = %s, want process", got)
+	}
+}
+
+func TestBaseNodePayloadMiddleware(t *testing.T) {
+	errEmpty := errors.New("empty payload")
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	n.Use(node.PayloadMiddleware(func(next node.ProcessFunc) node.ProcessFunc {
+		return func(input []byte) ([]byte, error) {
+			if len(input) == 0 {
+				return nil, errEmpty
+			}
+			return next(bytes.TrimSpace(input))
+		}
+	}))
+	n.SetProcessFunc(func(input []byte) ([]byte, error) {
+		return append([]byte("<"), append(input, '>')...), nil
+	})
+
+	msg := node.NewMessage("source", []byte("  padded  "))
+	msg.SetHeader("tenant", "acme")
+	out, err := n.ProcessMessage(msg)
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if string(out.Payload) != "<padded>" {
+		t.Errorf("output = %q, want <padded>", out.Payload)
+	}
+	if out.Header("tenant") != "acme" {
+		t.Errorf("output lost headers: %v", out.Headers)
+	}
+
+	if _, err := n.Process(nil); !errors.Is(err, errEmpty) {
+		t.Errorf("Process(nil) error = %v, want %v", err, errEmpty)
+	}
+}
+
+func TestBaseNodeMiddlewareConcurrentUse(t *testing.T) {
+	const numWorkers = 50
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			n.Use(func(next node.MessageFunc) node.MessageFunc { return next })
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := n.Process([]byte("data")); err != nil {
+				t.Errorf("Process() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,133 @@
+package node_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// panickyNode implements only the Node interface and panics on every event.
+type panickyNode struct {
+	*node.BaseNode
+}
+
+func (p *panickyNode) Process([]byte) ([]byte, error) {
+	panic("plugin exploded")
+}
+
+func TestBaseNodePanicRecoveryInNotify(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	bad := node.NewBaseNode("bad")
+	good := node.NewBaseNode("good")
+	foreign := struct{ node.Node }{&panickyNode{node.NewBaseNode("foreign")}}
+	createNodes(t, publisher, bad, good, foreign)
+
+	bad.SetProcessFunc(func([]byte) ([]byte, error) {
+		panic("boom")
+	})
+	for _, sub := range []node.Node{bad, good, foreign} {
+		if err := publisher.Subscribe(sub); err != nil {
+			t.Fatalf("Subscribe(%s) error = %v", sub.GetID(), err)
+		}
+	}
+
+	const numEvents = 10
+	for i := 0; i < numEvents; i++ {
+		err := publisher.Notify([]byte("event"))
+		if err == nil {
+			t.Fatal("Notify() error = nil, want delivery errors")
+		}
+
+		var perr *node.PanicError
+		if !errors.As(err, &perr) {
+			t.Fatalf("Notify() error = %v, want *PanicError", err)
+		}
+		if len(perr.Stack) == 0 {
+			t.Error("PanicError.Stack is empty")
+		}
+		if !strings.Contains(err.Error(), "boom") || !strings.Contains(err.Error(), "plugin exploded") {
+			t.Errorf("Notify() error = %v, want both panics reported", err)
+		}
+	}
+
+	if got := good.GetEventCount(); got != numEvents {
+		t.Errorf("good: GetEventCount() = %d, want %d", got, numEvents)
+	}
+	if got := bad.GetPanicCount(); got != numEvents {
+		t.Errorf("bad: GetPanicCount() = %d, want %d", got, numEvents)
+	}
+	if bad.IsQuarantined() {
+		t.Error("bad: quarantined under the default PanicContinue policy")
+	}
+}
+
+func TestBaseNodePanicQuarantine(t *testing.T) {
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	n.SetPanicPolicy(node.PanicPolicy{Action: node.PanicQuarantine, QuarantineAfter: 3})
+	n.SetProcessFunc(func(input []byte) ([]byte, error) {
+		if string(input) == "bad" {
+			panic("bad input")
+		}
+		return input, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := n.Process([]byte("bad")); err == nil {
+			t.Fatalf("Process(bad) #%d error = nil, want *PanicError", i)
+		}
+	}
+	if !n.IsQuarantined() {
+		t.Fatal("node not quarantined after 3 panics")
+	}
+	if _, err := n.Process([]byte("good")); !errors.Is(err, node.ErrQuarantined) {
+		t.Errorf("Process() on quarantined node error = %v, want ErrQuarantined", err)
+	}
+
+	n.ReleaseQuarantine()
+	if out, err := n.Process([]byte("good")); err != nil || string(out) != "good" {
+		t.Errorf("Process() after ReleaseQuarantine = %q, %v", out, err)
+	}
+
+	// The count starts over after a release.
+	for i := 0; i < 2; i++ {
+		n.Process([]byte("bad"))
+	}
+	if n.IsQuarantined() {
+		t.Error("node quarantined again after 2 panics following the release")
+	}
+	if got := n.GetPanicCount(); got != 5 {
+		t.Errorf("GetPanicCount() = %d, want 5", got)
+	}
+	n.Process([]byte("bad"))
+	if !n.IsQuarantined() {
+		t.Error("node not quarantined after 3 panics following the release")
+	}
+}
+
+func TestBaseNodePanicRethrow(t *testing.T) {
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	n.SetPanicPolicy(node.PanicPolicy{Action: node.PanicRethrow})
+	n.SetProcessFunc(func([]byte) ([]byte, error) {
+		panic(errors.New("fatal"))
+	})
+
+	defer func() {
+		r := recover()
+		perr, ok := r.(*node.PanicError)
+		if !ok {
+			t.Fatalf("recovered %v, want *PanicError", r)
+		}
+		if perr.NodeID != "node" || perr.Unwrap() == nil || perr.Unwrap().Error() != "fatal" {
+			t.Errorf("PanicError = %+v", perr)
+		}
+		if n.GetPanicCount() != 1 {
+			t.Errorf("GetPanicCount() = %d, want 1", n.GetPanicCount())
+		}
+	}()
+	n.Process([]byte("event"))
+	t.Fatal("Process() returned, want panic")
+}
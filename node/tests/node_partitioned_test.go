@@ -0,0 +1,135 @@
+package node_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func accountKey(msg *node.Message) string {
+	return strings.SplitN(string(msg.Payload), ":", 2)[0]
+}
+
+func TestPartitionedNodeSerializesPerKey(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		active   = make(map[string]int)
+		maxKey   int
+		running  int32
+		maxTotal int32
+		order    = make(map[string][]string)
+	)
+	p := node.NewPartitionedNode("accounts", 4, accountKey, func(input []byte) ([]byte, error) {
+		key := strings.SplitN(string(input), ":", 2)[0]
+		mu.Lock()
+		active[key]++
+		if active[key] > maxKey {
+			maxKey = active[key]
+		}
+		order[key] = append(order[key], string(input))
+		mu.Unlock()
+		if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxTotal) {
+			atomic.StoreInt32(&maxTotal, n)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		mu.Lock()
+		active[key]--
+		mu.Unlock()
+		return input, nil
+	})
+	publisher := node.NewBaseNode("publisher")
+	createNodes(t, publisher, p)
+	publisher.Subscribe(p)
+
+	// Each account publishes concurrently from several goroutines.
+	var wg sync.WaitGroup
+	for a := 0; a < 8; a++ {
+		for g := 0; g < 3; g++ {
+			wg.Add(1)
+			go func(a, g int) {
+				defer wg.Done()
+				for i := 0; i < 5; i++ {
+					if err := publisher.Notify([]byte(fmt.Sprintf("acct%d:%d-%d", a, g, i))); err != nil {
+						t.Error(err)
+					}
+				}
+			}(a, g)
+		}
+	}
+	wg.Wait()
+
+	if maxKey != 1 {
+		t.Errorf("an account was processed by %d workers at once, want 1", maxKey)
+	}
+	if maxTotal < 2 {
+		t.Errorf("at most %d messages ran at once, want accounts processed in parallel", maxTotal)
+	}
+	// Events published in order by one goroutine are processed in order.
+	for key, events := range order {
+		last := make(map[string]string)
+		for _, e := range events {
+			g := strings.SplitN(strings.SplitN(e, ":", 2)[1], "-", 2)[0]
+			if prev, ok := last[g]; ok && prev > e {
+				t.Errorf("%s: %s processed after %s", key, e, prev)
+			}
+			last[g] = e
+		}
+	}
+	var total uint64
+	for _, n := range p.GetPartitionCounts() {
+		total += n
+	}
+	if total != 120 || len(p.GetPartitionCounts()) != p.Workers() {
+		t.Errorf("GetPartitionCounts() = %v, want 120 messages over 4 workers", p.GetPartitionCounts())
+	}
+}
+
+func TestPartitionedNodeLifecycleAndErrors(t *testing.T) {
+	boom := errors.New("boom")
+	p := node.NewPartitionedNode("partitioned", 2, accountKey, func(input []byte) ([]byte, error) {
+		if strings.HasSuffix(string(input), "fail") {
+			return nil, boom
+		}
+		return append([]byte("ok "), input...), nil
+	})
+	var state *node.StateError
+	if _, err := p.Process([]byte("a:1")); !errors.As(err, &state) {
+		t.Errorf("Process() before Create error = %v, want a StateError", err)
+	}
+	createNodes(t, p)
+
+	if out, err := p.Process([]byte("a:1")); err != nil || string(out) != "ok a:1" {
+		t.Errorf("Process() = %q, %v", out, err)
+	}
+	if _, err := p.Process([]byte("a:fail")); !errors.Is(err, boom) {
+		t.Errorf("Process() error = %v, want boom", err)
+	}
+	if p.Partition("a") != p.Partition("a") || p.Partition("a") >= p.Workers() {
+		t.Errorf("Partition(a) = %d", p.Partition("a"))
+	}
+
+	sink := newRecorder("sink")
+	createNodes(t, sink)
+	p.Subscribe(sink)
+	p.SetForwarding(node.ForwardConfig{})
+	if _, err := p.Process([]byte("b:2")); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if got := sink.payloads(); len(got) != 1 || got[0] != "ok b:2" {
+		t.Errorf("sink received %q", got)
+	}
+
+	if err := p.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := p.Process([]byte("a:2")); !errors.As(err, &state) {
+		t.Errorf("Process() after Delete error = %v, want a StateError", err)
+	}
+}
@@ -0,0 +1,122 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodePauseBuffersUntilResume(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	stage := node.NewBaseNode("stage")
+	createNodes(t, publisher, stage)
+	stage.SetPausePolicy(node.PausePolicy{Capacity: 3})
+
+	var mu sync.Mutex
+	var seen []string
+	stage.SetProcessFunc(func(input []byte) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, string(input))
+		return input, nil
+	})
+	if err := publisher.Subscribe(stage); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	if err := stage.Pause(); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if err := stage.Pause(); !errors.Is(err, node.ErrInvalidTransition) {
+		t.Errorf("second Pause() error = %v, want ErrInvalidTransition", err)
+	}
+	for _, event := range []string{"1", "2"} {
+		if out, err := stage.Process([]byte(event)); out != nil || err != nil {
+			t.Fatalf("Process(%s) while paused = %q, %v, want buffered", event, out, err)
+		}
+	}
+	if err := publisher.Notify([]byte("3")); err != nil {
+		t.Fatalf("Notify() to paused subscriber error = %v", err)
+	}
+	if _, err := stage.Process([]byte("4")); !errors.Is(err, node.ErrPaused) {
+		t.Errorf("Process() with full buffer error = %v, want ErrPaused", err)
+	}
+	if len(seen) != 0 || stage.GetBufferedCount() != 3 {
+		t.Fatalf("seen = %v, buffered = %d, want nothing processed and 3 buffered", seen, stage.GetBufferedCount())
+	}
+
+	if err := stage.Resume(); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if got := strings.Join(seen, ","); got != "1,2,3" {
+		t.Errorf("processed after Resume = %s, want 1,2,3", got)
+	}
+	if stage.GetState() != node.StateRunning || stage.GetBufferedCount() != 0 {
+		t.Errorf("state = %v, buffered = %d after Resume", stage.GetState(), stage.GetBufferedCount())
+	}
+	if err := stage.Resume(); !errors.Is(err, node.ErrInvalidTransition) {
+		t.Errorf("Resume() on running node error = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestBaseNodePauseReject(t *testing.T) {
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	n.SetPausePolicy(node.PausePolicy{Mode: node.PauseReject})
+	if err := n.Pause(); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if _, err := n.Process([]byte("event")); !errors.Is(err, node.ErrPaused) {
+		t.Errorf("Process() error = %v, want ErrPaused", err)
+	}
+	if err := n.Resume(); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if out, err := n.Process([]byte("event")); err != nil || string(out) != "event" {
+		t.Errorf("Process() after Resume = %q, %v", out, err)
+	}
+}
+
+func TestBaseNodeResumeReportsErrors(t *testing.T) {
+	errBad := errors.New("bad event")
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	n.SetProcessFunc(func(input []byte) ([]byte, error) {
+		if string(input) == "bad" {
+			return nil, errBad
+		}
+		return input, nil
+	})
+	n.Pause()
+	n.Process([]byte("good"))
+	n.Process([]byte("bad"))
+	if err := n.Resume(); !errors.Is(err, errBad) {
+		t.Errorf("Resume() error = %v, want %v", err, errBad)
+	}
+	if n.GetEventCount() != 2 {
+		t.Errorf("GetEventCount() = %d, want 2", n.GetEventCount())
+	}
+}
+
+func TestBaseNodeDeleteWhilePausedReportsBuffered(t *testing.T) {
+	n := node.NewBaseNode("node")
+	createNodes(t, n)
+	n.Pause()
+	n.Process([]byte("held 1"))
+	n.Process([]byte("held 2"))
+
+	report, err := n.DeleteContext(context.Background())
+	if !errors.Is(err, node.ErrPaused) {
+		t.Errorf("DeleteContext() error = %v, want ErrPaused", err)
+	}
+	if len(report.Abandoned) != 2 || string(report.Abandoned[0].Message.Payload) != "held 1" {
+		t.Errorf("Abandoned = %+v, want both buffered events in order", report.Abandoned)
+	}
+	if n.GetState() != node.StateStopped {
+		t.Errorf("GetState() = %v, want stopped", n.GetState())
+	}
+}
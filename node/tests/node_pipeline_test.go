@@ -0,0 +1,193 @@
+package node_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// recorder is a sink that records the payloads it receives.
+type recorder struct {
+	*node.BaseNode
+	mu   sync.Mutex
+	seen []string
+}
+
+func newRecorder(id string) *recorder {
+	r := &recorder{BaseNode: node.NewBaseNode(id)}
+	r.SetProcessFunc(func(input []byte) ([]byte, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.seen = append(r.seen, string(input))
+		return input, nil
+	})
+	return r
+}
+
+func (r *recorder) payloads() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := append([]string(nil), r.seen...)
+	sort.Strings(out)
+	return out
+}
+
+func TestPipelineBranchAndMerge(t *testing.T) {
+	source := node.NewBaseNode("source")
+	upper := node.NewBaseNode("upper")
+	upper.SetProcessFunc(func(input []byte) ([]byte, error) {
+		return bytes.ToUpper(input), nil
+	})
+	tag := func(id, suffix string) *node.BaseNode {
+		n := node.NewBaseNode(id)
+		n.SetProcessFunc(func(input []byte) ([]byte, error) {
+			return append(input, suffix...), nil
+		})
+		return n
+	}
+	alerts, metrics := tag("alerts", "!"), tag("metrics", ".")
+	sink := newRecorder("sink")
+	isAlert := func(payload []byte) bool { return bytes.HasPrefix(payload, []byte("ALERT")) }
+
+	p := node.NewPipeline().
+		Source(source).
+		Then(upper).
+		Branch(isAlert, alerts, metrics).
+		Merge(node.NewBaseNode("merge")).
+		Sink(sink)
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer p.Stop(context.Background())
+
+	for _, event := range []string{"alert: disk", "cpu 40", "alert: fan"} {
+		if err := p.Send([]byte(event)); err != nil {
+			t.Fatalf("Send(%q) error = %v", event, err)
+		}
+	}
+	want := []string{"ALERT: DISK!", "ALERT: FAN!", "CPU 40."}
+	if got := sink.payloads(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sink received %q, want %q", got, want)
+	}
+	if n := len(p.Stages()); n != 6 {
+		t.Errorf("len(Stages()) = %d, want 6", n)
+	}
+	if report := p.Runtime().Analyze(node.GraphOptions{}); report.HasCycles() || len(report.Unreachable) != 0 {
+		t.Errorf("Analyze() = %+v", report)
+	}
+}
+
+func TestPipelineReportsStageErrors(t *testing.T) {
+	boom := errors.New("boom")
+	failing := node.NewBaseNode("failing")
+	failing.SetProcessFunc(func(input []byte) ([]byte, error) {
+		if string(input) == "bad" {
+			return nil, boom
+		}
+		return input, nil
+	})
+	sink := newRecorder("sink")
+	p := node.NewPipeline().
+		Source(node.NewBaseNode("source")).
+		ThenFunc("trim", func(input []byte) ([]byte, error) {
+			return bytes.TrimSpace(input), nil
+		}).
+		Then(failing).
+		Sink(sink)
+	if err := p.Send([]byte("early")); !errors.Is(err, node.ErrPipelineNotStarted) {
+		t.Errorf("Send() before Start error = %v, want ErrPipelineNotStarted", err)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	err := p.Send([]byte(" bad "))
+	var de *node.DeliveryError
+	if !errors.Is(err, boom) || !errors.As(err, &de) {
+		t.Errorf("Send(bad) error = %v, want boom wrapped in a DeliveryError", err)
+	}
+	if err := p.Send([]byte(" good ")); err != nil {
+		t.Errorf("Send(good) error = %v", err)
+	}
+	if got := sink.payloads(); len(got) != 1 || got[0] != "good" {
+		t.Errorf("sink received %q, want [good]", got)
+	}
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	for _, stage := range p.Stages() {
+		if s := stage.(interface{ GetState() node.State }).GetState(); s != node.StateStopped {
+			t.Errorf("%s: state = %v after Stop, want stopped", stage.GetID(), s)
+		}
+	}
+}
+
+func TestPipelineValidation(t *testing.T) {
+	a := node.NewBaseNode("a")
+	tests := []struct {
+		name string
+		p    *node.Pipeline
+		want string
+	}{
+		{"no source", node.NewPipeline().Then(a), "Source must be the first stage"},
+		{"empty", node.NewPipeline(), "no source"},
+		{"stage twice", node.NewPipeline().Source(a).Then(a), "stage a added twice"},
+		{"duplicate id", node.NewPipeline().Source(a).Then(node.NewBaseNode("a")), "duplicate"},
+		{"after sink", node.NewPipeline().Source(a).Sink(node.NewBaseNode("b")).Then(node.NewBaseNode("c")), "no stages can follow Sink"},
+		{"nil predicate", node.NewPipeline().Source(a).Branch(nil, node.NewBaseNode("b"), nil), "Branch needs a predicate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.p.Start()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Start() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineWiresStagesOnStart(t *testing.T) {
+	source := node.NewBaseNode("source")
+	mid := node.NewBaseNode("mid")
+	sink := node.NewBaseNode("sink")
+	sink.OnStart(func() error { return errors.New("sink unavailable") })
+	p := node.NewPipeline().Source(source).Then(mid).Sink(sink)
+	if source.SubscriptionCount() != 0 || mid.SubscriptionCount() != 0 {
+		t.Error("stages subscribed to each other before Start")
+	}
+
+	if err := p.Start(); err == nil {
+		t.Fatal("Start() error = nil, want the failing OnStart hook")
+	}
+	for _, n := range []*node.BaseNode{source, mid} {
+		if n.SubscriptionCount() != 0 {
+			t.Errorf("%s: %d subscriptions after failed Start, want 0", n.GetID(), n.SubscriptionCount())
+		}
+		if _, ok := n.GetForwarding(); ok {
+			t.Errorf("%s: forwarding still enabled after failed Start", n.GetID())
+		}
+	}
+}
+
+func TestPipelineRejectsCycleThroughExistingSubscriptions(t *testing.T) {
+	a := node.NewBaseNode("a")
+	b := node.NewBaseNode("b")
+	c := node.NewBaseNode("c")
+	if err := c.Subscribe(a); err != nil {
+		t.Fatalf("Subscribe(c -> a) error = %v", err)
+	}
+	p := node.NewPipeline().Source(a).Then(b).Sink(c)
+	if err := p.Start(); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Start() error = %v, want a cycle", err)
+	}
+	if a.SubscriptionCount() != 0 || b.SubscriptionCount() != 0 {
+		t.Error("stages subscribed to each other after failed validation")
+	}
+}
@@ -0,0 +1,132 @@
+package node_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeProcessRateLimit(t *testing.T) {
+	t.Run("reject", func(t *testing.T) {
+		n := node.NewBaseNode("node")
+		createNodes(t, n)
+		n.SetRateLimit(node.RateLimit{Rate: 1, Burst: 2, Mode: node.RateLimitReject})
+
+		var limited int
+		for i := 0; i < 5; i++ {
+			if _, err := n.Process([]byte("event")); errors.Is(err, node.ErrRateLimited) {
+				limited++
+			} else if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+		}
+		if limited != 3 || n.GetEventCount() != 2 {
+			t.Errorf("limited = %d, processed = %d, want 3 and 2", limited, n.GetEventCount())
+		}
+		if n.GetRateLimiter().Dropped() != 3 {
+			t.Errorf("Dropped() = %d, want 3", n.GetRateLimiter().Dropped())
+		}
+
+		// Lifting the limit at runtime lets everything through.
+		n.SetRateLimit(node.RateLimit{})
+		for i := 0; i < 5; i++ {
+			if _, err := n.Process([]byte("event")); err != nil {
+				t.Fatalf("Process() after lifting the limit error = %v", err)
+			}
+		}
+	})
+
+	t.Run("sample", func(t *testing.T) {
+		n := node.NewBaseNode("node")
+		createNodes(t, n)
+		n.SetRateLimit(node.RateLimit{Rate: 1, Burst: 2, Mode: node.RateLimitSample})
+		for i := 0; i < 5; i++ {
+			if _, err := n.Process([]byte("event")); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+		}
+		if n.GetEventCount() != 2 {
+			t.Errorf("processed = %d, want 2", n.GetEventCount())
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		n := node.NewBaseNode("node")
+		createNodes(t, n)
+		n.SetRateLimit(node.RateLimit{Rate: 100, Burst: 1})
+		start := time.Now()
+		for i := 0; i < 6; i++ {
+			if _, err := n.Process([]byte("event")); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+			t.Errorf("6 events at 100/s took %v, want at least 50ms", elapsed)
+		}
+	})
+}
+
+func TestBaseNodeSubscriptionRateLimit(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	paid := node.NewBaseNode("paid-api")
+	free := node.NewBaseNode("free")
+	createNodes(t, publisher, paid, free)
+
+	limit := node.RateLimit{Rate: 1, Burst: 1, Mode: node.RateLimitReject}
+	if err := publisher.Subscribe(paid, node.WithRateLimit(limit)); err != nil {
+		t.Fatalf("Subscribe(paid) error = %v", err)
+	}
+	if err := publisher.Subscribe(free); err != nil {
+		t.Fatalf("Subscribe(free) error = %v", err)
+	}
+
+	var limited int
+	for i := 0; i < 4; i++ {
+		if err := publisher.Notify([]byte("event")); errors.Is(err, node.ErrRateLimited) {
+			limited++
+		}
+	}
+	if limited != 3 || paid.GetEventCount() != 1 || free.GetEventCount() != 4 {
+		t.Errorf("limited = %d, paid = %d, free = %d, want 3, 1, 4", limited, paid.GetEventCount(), free.GetEventCount())
+	}
+
+	limiter := publisher.GetSubscriptionRateLimiter("paid-api")
+	if limiter == nil {
+		t.Fatal("GetSubscriptionRateLimiter() = nil")
+	}
+	limiter.SetLimit(node.RateLimit{Rate: 1, Burst: 1, Mode: node.RateLimitSample})
+	for i := 0; i < 4; i++ {
+		if err := publisher.Notify([]byte("event")); err != nil {
+			t.Errorf("Notify() with sampling error = %v", err)
+		}
+	}
+	if paid.GetEventCount() > 2 {
+		t.Errorf("paid processed %d events, want sampled deliveries dropped", paid.GetEventCount())
+	}
+	if publisher.GetSubscriptionRateLimiter("free") != nil {
+		t.Error("GetSubscriptionRateLimiter(free) != nil for an unlimited subscription")
+	}
+}
+
+func TestBaseNodeSubscriptionStatsCountSampledEvents(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	sampled := node.NewBaseNode("sampled")
+	createNodes(t, publisher, sampled)
+	limit := node.RateLimit{Rate: 0.001, Burst: 1, Mode: node.RateLimitSample}
+	if err := publisher.Subscribe(sampled, node.WithRateLimit(limit)); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := publisher.Notify([]byte("event")); err != nil {
+			t.Errorf("Notify() error = %v", err)
+		}
+	}
+	stats, _ := publisher.GetSubscriptionStats("sampled")
+	want := node.SubscriptionStats{Delivered: 1, Sampled: 4}
+	if stats != want || sampled.GetEventCount() != 1 {
+		t.Errorf("stats = %+v with %d events processed, want %+v", stats, sampled.GetEventCount(), want)
+	}
+}
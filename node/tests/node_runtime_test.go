@@ -0,0 +1,140 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestRuntimeLifecycleOrder(t *testing.T) {
+	rt := node.NewRuntime()
+	var trace []string
+	for _, id := range []string{"source", "transform", "sink"} {
+		id := id
+		n := node.NewBaseNode(id)
+		n.OnStart(func() error { trace = append(trace, "start "+id); return nil })
+		n.OnStop(func() error { trace = append(trace, "stop "+id); return nil })
+		if err := rt.Add(n); err != nil {
+			t.Fatalf("Add(%s) error = %v", id, err)
+		}
+	}
+
+	if got := strings.Join(rt.IDs(), ","); got != "source,transform,sink" {
+		t.Errorf("IDs() = %s", got)
+	}
+	if err := rt.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := rt.Start(); !errors.Is(err, node.ErrRuntimeStarted) {
+		t.Errorf("second Start() error = %v, want ErrRuntimeStarted", err)
+	}
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	want := "start source,start transform,start sink,stop sink,stop transform,stop source"
+	if got := strings.Join(trace, ","); got != want {
+		t.Errorf("lifecycle order = %s, want %s", got, want)
+	}
+}
+
+func TestRuntimeDuplicateIDs(t *testing.T) {
+	rt := node.NewRuntime()
+	first := node.NewBaseNode("dup")
+	if err := rt.Add(first); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := rt.Add(node.NewBaseNode("other"), node.NewBaseNode("dup")); !errors.Is(err, node.ErrDuplicateID) {
+		t.Errorf("Add(duplicate) error = %v, want ErrDuplicateID", err)
+	}
+	if rt.Len() != 1 || rt.Get("other") != nil {
+		t.Errorf("failed Add() registered nodes: %v", rt.IDs())
+	}
+	if rt.Get("dup") != first {
+		t.Error("Get(dup) did not return the first node")
+	}
+
+	// Two distinct nodes sharing an ID must not collapse into one
+	// subscription either.
+	publisher := node.NewBaseNode("publisher")
+	if err := publisher.Subscribe(first); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := publisher.Subscribe(first, node.WithFilterExpr(`kind == "a"`)); err != nil {
+		t.Errorf("re-Subscribe() of the same node error = %v", err)
+	}
+	if err := publisher.Subscribe(node.NewBaseNode("dup")); !errors.Is(err, node.ErrDuplicateID) {
+		t.Errorf("Subscribe(impostor) error = %v, want ErrDuplicateID", err)
+	}
+}
+
+func TestRuntimeWiringByID(t *testing.T) {
+	rt := node.NewRuntime()
+	source := node.NewBaseNode("source")
+	sink := node.NewBaseNode("sink")
+	if err := rt.Add(source, sink); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := rt.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer rt.Shutdown(context.Background())
+
+	if err := rt.Connect("source", "sink"); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if err := rt.Connect("source", "missing"); !errors.Is(err, node.ErrNodeNotFound) {
+		t.Errorf("Connect(missing) error = %v, want ErrNodeNotFound", err)
+	}
+	source.Notify([]byte("event"))
+	if sink.GetEventCount() != 1 {
+		t.Errorf("sink: GetEventCount() = %d, want 1", sink.GetEventCount())
+	}
+
+	// Nodes added to a started runtime start right away.
+	late := node.NewBaseNode("late")
+	if err := rt.Add(late); err != nil {
+		t.Fatalf("Add(late) error = %v", err)
+	}
+	if late.GetState() != node.StateRunning {
+		t.Errorf("late: GetState() = %v, want running", late.GetState())
+	}
+	if err := rt.Connect("source", "late"); err != nil {
+		t.Fatalf("Connect(late) error = %v", err)
+	}
+
+	if err := rt.Disconnect("source", "sink"); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	if err := rt.Remove("late"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if source.GetSubscription("sink") != nil || source.GetSubscription("late") != nil {
+		t.Error("source still has subscriptions after Disconnect and Remove")
+	}
+	if late.GetState() != node.StateStopped || rt.Get("late") != nil {
+		t.Errorf("removed node state = %v, still registered = %v", late.GetState(), rt.Get("late") != nil)
+	}
+}
+
+func TestRuntimeStartFailureRollsBack(t *testing.T) {
+	errBoom := errors.New("boom")
+	rt := node.NewRuntime()
+	first := node.NewBaseNode("first")
+	broken := node.NewBaseNode("broken")
+	broken.OnStart(func() error { return errBoom })
+	rt.Add(first, broken, node.NewBaseNode("never"))
+
+	if err := rt.Start(); !errors.Is(err, errBoom) {
+		t.Fatalf("Start() error = %v, want %v", err, errBoom)
+	}
+	if first.GetState() != node.StateStopped {
+		t.Errorf("first: GetState() = %v, want stopped after rollback", first.GetState())
+	}
+	if state := rt.Get("never").(*node.BaseNode).GetState(); state != node.StateNew {
+		t.Errorf("never: GetState() = %v, want new", state)
+	}
+}
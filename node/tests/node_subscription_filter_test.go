@@ -0,0 +1,102 @@
+package node_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeSubscriptionFilters(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	hot := node.NewBaseNode("hot")
+	prefixed := node.NewBaseNode("prefixed")
+	all := node.NewBaseNode("all")
+
+	for _, n := range []*node.BaseNode{publisher, hot, prefixed, all} {
+		if err := n.Create(); err != nil {
+			t.Fatalf("%s: Create() error = %v", n.GetID(), err)
+		}
+	}
+
+	if err := publisher.Subscribe(hot, node.WithFilterExpr(`temp > 30 && site == "A"`)); err != nil {
+		t.Fatalf("Subscribe(hot) error = %v", err)
+	}
+	if err := publisher.Subscribe(prefixed, node.WithFilter(func(event []byte) bool {
+		return bytes.HasPrefix(event, []byte(`{"temp"`))
+	})); err != nil {
+		t.Fatalf("Subscribe(prefixed) error = %v", err)
+	}
+	if err := publisher.Subscribe(all); err != nil {
+		t.Fatalf("Subscribe(all) error = %v", err)
+	}
+
+	events := []string{
+		`{"temp": 35, "site": "A"}`,
+		`{"temp": 35, "site": "B"}`,
+		`{"temp": 10, "site": "A"}`,
+		`{"site": "A", "temp": 40}`,
+		`not json`,
+	}
+	for _, event := range events {
+		if err := publisher.Notify([]byte(event)); err != nil {
+			t.Fatalf("Notify(%s) error = %v", event, err)
+		}
+	}
+
+	if got := hot.GetEventCount(); got != 2 {
+		t.Errorf("hot: GetEventCount() = %d, want 2", got)
+	}
+	if got := prefixed.GetEventCount(); got != 3 {
+		t.Errorf("prefixed: GetEventCount() = %d, want 3", got)
+	}
+	if got := all.GetEventCount(); got != uint64(len(events)) {
+		t.Errorf("all: GetEventCount() = %d, want %d", got, len(events))
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		expr  string
+		event string
+		want  bool
+	}{
+		{`temp > 30`, `{"temp": 31}`, true},
+		{`temp > 30`, `{"temp": 30}`, false},
+		{`temp >= 30 && temp <= 40`, `{"temp": 30}`, true},
+		{`site == "A" || site == 'B'`, `{"site": "B"}`, true},
+		{`!(site == "A")`, `{"site": "A"}`, false},
+		{`reading.quality >= 0.9`, `{"reading": {"quality": 0.95}}`, true},
+		{`missing == null`, `{"temp": 1}`, true},
+		{`missing > 1`, `{"temp": 1}`, false},
+		{`active`, `{"active": true}`, true},
+		{`active && temp < -5`, `{"active": true, "temp": -10}`, true},
+		{`name != "x"`, `[1, 2, 3]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := node.CompileFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileFilter(%q) error = %v", tt.expr, err)
+			}
+			if got := filter([]byte(tt.event)); got != tt.want {
+				t.Errorf("filter(%s) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+
+	for _, expr := range []string{`temp >`, `(temp > 1`, `site == "A`, `temp # 1`, `a..b == 1`} {
+		if _, err := node.CompileFilter(expr); err == nil {
+			t.Errorf("CompileFilter(%q) error = nil, want error", expr)
+		}
+	}
+
+	n := node.NewBaseNode("n")
+	if err := n.Subscribe(node.NewBaseNode("other"), node.WithFilterExpr(`temp >`)); err == nil {
+		t.Error("Subscribe() with invalid expression error = nil, want error")
+	}
+	if n.GetSubscription("other") != nil {
+		t.Error("Subscribe() with invalid expression registered the subscription")
+	}
+}
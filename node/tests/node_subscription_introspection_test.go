@@ -0,0 +1,92 @@
+package node_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+func TestBaseNodeSubscriptions(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	slow := node.NewBaseNode("slow")
+	guarded := node.NewBaseNode("guarded")
+	custom := node.NewBaseNode("custom")
+	createNodes(t, publisher, slow, guarded, custom)
+	slow.SetProcessFunc(func(input []byte) ([]byte, error) {
+		time.Sleep(20 * time.Millisecond)
+		return input, nil
+	})
+
+	before := time.Now()
+	publisher.Subscribe(slow, node.WithFilterExpr(`temp > 25`))
+	publisher.Subscribe(guarded,
+		node.WithCircuitBreaker(node.CircuitBreakerConfig{}),
+		node.WithRateLimit(node.RateLimit{Rate: 100}))
+	publisher.Subscribe(custom, node.WithFilter(func([]byte) bool { return false }))
+
+	publisher.Notify([]byte(`{"temp": 30}`))
+	publisher.Notify([]byte(`{"temp": 10}`))
+
+	if publisher.SubscriptionCount() != 3 {
+		t.Errorf("SubscriptionCount() = %d, want 3", publisher.SubscriptionCount())
+	}
+	infos := publisher.Subscriptions()
+	if len(infos) != 3 {
+		t.Fatalf("Subscriptions() returned %d entries, want 3", len(infos))
+	}
+	byID := make(map[string]node.SubscriptionInfo)
+	for i, info := range infos {
+		byID[info.ID] = info
+		if want := []string{"custom", "guarded", "slow"}[i]; info.ID != want {
+			t.Errorf("Subscriptions()[%d].ID = %s, want %s", i, info.ID, want)
+		}
+		if info.SubscribedAt.Before(before) {
+			t.Errorf("%s: SubscribedAt = %v, want after %v", info.ID, info.SubscribedAt, before)
+		}
+	}
+
+	s := byID["slow"]
+	if s.Filter != "temp > 25" || s.Delivered != 1 || s.Failed != 0 || s.Node != slow {
+		t.Errorf("slow = %+v", s)
+	}
+	if s.LastLatency < 20*time.Millisecond {
+		t.Errorf("slow: LastLatency = %v, want at least 20ms", s.LastLatency)
+	}
+	g := byID["guarded"]
+	if !g.HasBreaker || g.Breaker != node.BreakerClosed || !g.RateLimited || g.RateLimit.Rate != 100 || g.Delivered != 2 {
+		t.Errorf("guarded = %+v", g)
+	}
+	c := byID["custom"]
+	if c.Filter != "func" || c.Delivered != 0 || c.LastLatency != 0 || c.HasBreaker || c.RateLimited {
+		t.Errorf("custom = %+v", c)
+	}
+}
+
+func TestBaseNodeRangeSubscriptions(t *testing.T) {
+	publisher := node.NewBaseNode("publisher")
+	for i := 0; i < 100; i++ {
+		publisher.Subscribe(node.NewBaseNode(fmt.Sprintf("sub-%03d", i)))
+	}
+
+	var seen []string
+	publisher.RangeSubscriptions(func(info node.SubscriptionInfo) bool {
+		seen = append(seen, info.ID)
+		return len(seen) < 10
+	})
+	if len(seen) != 10 || seen[0] != "sub-000" || seen[9] != "sub-009" {
+		t.Errorf("RangeSubscriptions() visited %v, want the first 10 in order", seen)
+	}
+
+	// Unsubscribing during iteration is safe.
+	count := 0
+	publisher.RangeSubscriptions(func(info node.SubscriptionInfo) bool {
+		publisher.Unsubscribe(info.Node)
+		count++
+		return true
+	})
+	if count != 100 || publisher.SubscriptionCount() != 0 {
+		t.Errorf("visited %d, remaining %d, want 100 and 0", count, publisher.SubscriptionCount())
+	}
+}
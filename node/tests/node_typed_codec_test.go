@@ -0,0 +1,106 @@
+package node_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type reading struct {
+	Site string
+	Temp float64
+}
+
+type alert struct {
+	Site string
+	Hot  bool
+}
+
+func TestTypedNodeCodecs(t *testing.T) {
+	classify := func(r reading) (alert, error) {
+		return alert{Site: r.Site, Hot: r.Temp > 30}, nil
+	}
+
+	for _, codec := range []node.Codec{node.JSONCodec{}, node.GobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			n := node.NewTypedNode("classifier", codec, classify)
+			if err := n.Create(); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			input, err := codec.Marshal(reading{Site: "A", Temp: 35})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			out, err := n.ProcessMessage(node.NewMessage("sensor", input))
+			if err != nil {
+				t.Fatalf("ProcessMessage() error = %v", err)
+			}
+			if out.ContentType != codec.ContentType() {
+				t.Errorf("ContentType = %q, want %q", out.ContentType, codec.ContentType())
+			}
+
+			var got alert
+			if err := codec.Unmarshal(out.Payload, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got != (alert{Site: "A", Hot: true}) {
+				t.Errorf("output = %+v, want {A true}", got)
+			}
+
+			_, err = n.Process([]byte("\x00garbage"))
+			var decodeErr *node.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Process(garbage) error = %v, want *DecodeError", err)
+			}
+			if decodeErr.Codec != codec.Name() {
+				t.Errorf("DecodeError.Codec = %q, want %q", decodeErr.Codec, codec.Name())
+			}
+		})
+	}
+}
+
+func TestTypedNodeProtoCodec(t *testing.T) {
+	upper := node.NewTypedNode("upper", node.ProtoCodec{}, func(in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String(strings.ToUpper(in.GetValue())), nil
+	})
+	var received string
+	sink := node.NewTypedNode("sink", node.ProtoCodec{}, func(in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		received = in.GetValue()
+		return in, nil
+	})
+	createNodes(t, upper, sink)
+	if err := upper.Subscribe(sink); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	payload, err := upper.Encode(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	out, err := upper.Process(payload)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	decoded, err := upper.Decode(out)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.GetValue() != "HELLO" {
+		t.Errorf("output = %q, want HELLO", decoded.GetValue())
+	}
+
+	if err := upper.NotifyValue(wrapperspb.String("published")); err != nil {
+		t.Fatalf("NotifyValue() error = %v", err)
+	}
+	if received != "published" {
+		t.Errorf("sink received %q, want published", received)
+	}
+
+	if _, err := (node.ProtoCodec{}).Marshal("not a proto"); err == nil {
+		t.Error("ProtoCodec.Marshal(string) error = nil, want error")
+	}
+}
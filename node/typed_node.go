@@ -0,0 +1,79 @@
+package node
+
+// TypedNode is a BaseNode whose process function works on decoded values
+// instead of raw payloads. Incoming payloads are decoded into In and outputs
+// are encoded from Out using the node's Codec.
+type TypedNode[In, Out any] struct {
+	*BaseNode
+	codec Codec
+}
+
+// NewTypedNode creates a new TypedNode with a given ID, codec and process
+// function.
+func NewTypedNode[In, Out any](id string, codec Codec, fn func(In) (Out, error)) *TypedNode[In, Out] {
+	t := &TypedNode[In, Out]{
+		BaseNode: NewBaseNode(id),
+		codec:    codec,
+	}
+	t.SetTypedFunc(fn)
+	return t
+}
+
+// SetTypedFunc allows setting a custom typed process function. Payloads that
+// cannot be decoded fail with a *DecodeError and outputs that cannot be
+// encoded fail with an *EncodeError.
+func (t *TypedNode[In, Out]) SetTypedFunc(fn func(In) (Out, error)) {
+	t.SetMessageFunc(func(msg *Message) (*Message, error) {
+		in, err := t.Decode(msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		out, err := fn(in)
+		if err != nil {
+			return nil, err
+		}
+		return t.encodeMessage(msg, out)
+	})
+}
+
+// Codec returns the codec used by the node.
+func (t *TypedNode[In, Out]) Codec() Codec {
+	return t.codec
+}
+
+// Decode decodes a payload into an input value.
+func (t *TypedNode[In, Out]) Decode(payload []byte) (In, error) {
+	var in In
+	if err := t.codec.Unmarshal(payload, &in); err != nil {
+		return in, &DecodeError{Codec: t.codec.Name(), Err: err}
+	}
+	return in, nil
+}
+
+// Encode encodes an output value into a payload.
+func (t *TypedNode[In, Out]) Encode(out Out) ([]byte, error) {
+	payload, err := t.codec.Marshal(out)
+	if err != nil {
+		return nil, &EncodeError{Codec: t.codec.Name(), Err: err}
+	}
+	return payload, nil
+}
+
+// NotifyValue encodes a value and sends it to all subscribed nodes.
+func (t *TypedNode[In, Out]) NotifyValue(v Out) error {
+	msg, err := t.encodeMessage(NewMessage(t.GetID(), nil), v)
+	if err != nil {
+		return err
+	}
+	return t.NotifyMessage(msg)
+}
+
+func (t *TypedNode[In, Out]) encodeMessage(parent *Message, v Out) (*Message, error) {
+	payload, err := t.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	msg := parent.Derive(payload)
+	msg.ContentType = t.codec.ContentType()
+	return msg, nil
+}
@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Aggregator computes the result of a window from the payloads of its
+// events, in event-time order.
+type Aggregator func(payloads [][]byte) ([]byte, error)
+
+// ValueFunc extracts a number from an event.
+type ValueFunc func(payload []byte) (float64, error)
+
+// JSONField returns a ValueFunc reading the numeric field name of a JSON
+// object.
+func JSONField(name string) ValueFunc {
+	return func(payload []byte) (float64, error) {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(payload, &obj); err != nil {
+			return 0, err
+		}
+		v, ok := obj[name].(float64)
+		if !ok {
+			return 0, fmt.Errorf("stream: field %q is not a number", name)
+		}
+		return v, nil
+	}
+}
+
+// Count aggregates a window into the number of its events.
+func Count(payloads [][]byte) ([]byte, error) {
+	return strconv.AppendInt(nil, int64(len(payloads)), 10), nil
+}
+
+// Sum aggregates a window into the sum of its values.
+func Sum(value ValueFunc) Aggregator {
+	return fold(value, 0, func(acc, v float64) float64 { return acc + v })
+}
+
+// Min aggregates a window into its smallest value.
+func Min(value ValueFunc) Aggregator {
+	return fold(value, math.Inf(1), math.Min)
+}
+
+// Max aggregates a window into its largest value.
+func Max(value ValueFunc) Aggregator {
+	return fold(value, math.Inf(-1), math.Max)
+}
+
+// Reducer aggregates a window by folding its payloads into initial with
+// fn, like Scan.
+func Reducer(initial []byte, fn func(acc, payload []byte) ([]byte, error)) Aggregator {
+	return func(payloads [][]byte) ([]byte, error) {
+		acc := initial
+		for _, p := range payloads {
+			var err error
+			if acc, err = fn(acc, p); err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	}
+}
+
+// fold aggregates the values of a window into a JSON number.
+func fold(value ValueFunc, initial float64, fn func(acc, v float64) float64) Aggregator {
+	return func(payloads [][]byte) ([]byte, error) {
+		acc := initial
+		for _, p := range payloads {
+			v, err := value(p)
+			if err != nil {
+				return nil, err
+			}
+			acc = fn(acc, v)
+		}
+		if math.IsInf(acc, 0) || math.IsNaN(acc) {
+			return []byte("null"), nil
+		}
+		return strconv.AppendFloat(nil, acc, 'g', -1, 64), nil
+	}
+}
@@ -0,0 +1,370 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// HeaderJoinSide overrides the side of a join an event is on. Its value is
+// "left" or "right".
+const HeaderJoinSide = "join-side"
+
+// DefaultJoinBuffer is the number of events a join buffers per key and
+// side when JoinConfig.MaxPerKey is zero.
+const DefaultJoinBuffer = 1024
+
+// MinJoinTTL is the TTL of joins whose JoinConfig.TTL is zero and whose
+// window is narrower, so that events of a zero-width window are buffered
+// long enough to meet their match.
+const MinJoinTTL = time.Second
+
+// ErrUnknownSide is returned by a join for events it cannot assign to
+// either input.
+var ErrUnknownSide = errors.New("event is from neither side of the join")
+
+// ErrInvalidBounds is returned by Create for joins whose lower bound is
+// after their upper bound.
+var ErrInvalidBounds = errors.New("lower bound is after upper bound")
+
+// JoinConfig configures a join node.
+type JoinConfig struct {
+	// Left and Right are the IDs of the nodes publishing each input. An
+	// event's side is taken from its Source, unless HeaderJoinSide is set.
+	Left, Right string
+	// Key extracts the join key of events on both sides. If RightKey is
+	// set, it is used for right events instead.
+	Key      KeyFunc
+	RightKey KeyFunc
+	// EventTime returns the time of an event. If nil, the message
+	// Timestamp is used.
+	EventTime func(msg *node.Message) time.Time
+	// Combine builds the output for a pair of matching events. Right is nil
+	// for unmatched left events of a left-outer join. If nil, a JoinResult
+	// is emitted.
+	Combine func(left, right []byte) ([]byte, error)
+	// TTL is how long events are buffered, by wall-clock arrival time,
+	// waiting for matches. If zero, it is the width of the join window, but
+	// at least MinJoinTTL.
+	TTL time.Duration
+	// MaxPerKey bounds the events buffered per key and side; the oldest
+	// is evicted first. If zero, DefaultJoinBuffer is used.
+	MaxPerKey int
+}
+
+// JoinResult is the payload emitted for a pair of events when
+// JoinConfig.Combine is nil, encoded as JSON. Payloads that are not JSON
+// are encoded as strings.
+type JoinResult struct {
+	Key   string          `json:"key"`
+	Left  json.RawMessage `json:"left"`
+	Right json.RawMessage `json:"right"`
+}
+
+// Join is a node that joins two input streams by key within a time window.
+// A left event at time t matches right events with the same key whose
+// event time lies in [t+lower, t+upper]; every match is emitted as soon as
+// its second event arrives. Events stay buffered until their TTL passes or
+// they are pushed out by MaxPerKey. A left-outer join emits left events
+// that never matched, with a nil right side, when they are evicted.
+//
+// A join processes events one at a time and emits matches as the output of
+// the event that completed them: a batch of results, or nil if there are
+// none. Unmatched left events of a left-outer join evicted by Sweep, or
+// still buffered when the node is deleted, are published directly.
+type Join struct {
+	*node.BaseNode
+	cfg          JoinConfig
+	lower, upper time.Duration
+	outer        bool
+
+	mu      sync.Mutex
+	sides   [2]map[string][]*joinEntry // buffered events by side and key
+	evicted uint64
+}
+
+// joinEntry is a buffered event.
+type joinEntry struct {
+	msg     *node.Message
+	at      time.Time // event time
+	expires time.Time // wall-clock eviction time
+	matched bool
+}
+
+const (
+	left = iota
+	right
+)
+
+// InnerJoin creates a join node emitting pairs of left and right events
+// with the same key whose event times are at most window apart. Create
+// fails with ErrInvalidBounds if window is negative.
+func InnerJoin(id string, window time.Duration, cfg JoinConfig) *Join {
+	return newJoin(id, -window, window, false, cfg)
+}
+
+// LeftJoin creates a join node like InnerJoin that also emits left events
+// without a match once they are evicted. Create fails with
+// ErrInvalidBounds if window is negative.
+func LeftJoin(id string, window time.Duration, cfg JoinConfig) *Join {
+	return newJoin(id, -window, window, true, cfg)
+}
+
+// IntervalJoin creates an inner join node emitting pairs where the right
+// event's time lies in [left+lower, left+upper], for example payments
+// made up to an hour after their order with lower 0 and upper time.Hour.
+// Create fails with ErrInvalidBounds if lower is after upper.
+func IntervalJoin(id string, lower, upper time.Duration, cfg JoinConfig) *Join {
+	return newJoin(id, lower, upper, false, cfg)
+}
+
+func newJoin(id string, lower, upper time.Duration, outer bool, cfg JoinConfig) *Join {
+	if cfg.TTL <= 0 {
+		cfg.TTL = upper - lower
+		if cfg.TTL < MinJoinTTL {
+			cfg.TTL = MinJoinTTL
+		}
+	}
+	if cfg.MaxPerKey <= 0 {
+		cfg.MaxPerKey = DefaultJoinBuffer
+	}
+	j := &Join{
+		BaseNode: newOrderedOperator(id),
+		cfg:      cfg,
+		lower:    lower,
+		upper:    upper,
+		outer:    outer,
+		sides:    [2]map[string][]*joinEntry{make(map[string][]*joinEntry), make(map[string][]*joinEntry)},
+	}
+	j.SetMessageFunc(j.process)
+	// Check the bounds before the sweep ticker starts.
+	j.OnStart(func() error {
+		if lower > upper {
+			return fmt.Errorf("stream: join %s: lower bound %v, upper bound %v: %w", id, lower, upper, ErrInvalidBounds)
+		}
+		return nil
+	})
+	every(j.BaseNode, cfg.TTL, func() { j.Sweep() })
+	j.OnStop(j.flush)
+	return j
+}
+
+// Buffered returns the number of events buffered on both sides.
+func (j *Join) Buffered() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n := 0
+	for _, side := range j.sides {
+		for _, entries := range side {
+			n += len(entries)
+		}
+	}
+	return n
+}
+
+// EvictedCount returns the number of events evicted by TTL or MaxPerKey.
+func (j *Join) EvictedCount() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.evicted
+}
+
+// Sweep evicts the buffered events whose TTL has passed. Joins sweep on
+// their own every TTL while running.
+func (j *Join) Sweep() error {
+	j.mu.Lock()
+	now := time.Now()
+	var (
+		outs []*node.Message
+		errs []error
+	)
+	for s := range j.sides {
+		for key := range j.sides[s] {
+			evicted, err := j.expire(s, key, now)
+			outs, errs = append(outs, evicted...), append(errs, err)
+		}
+	}
+	j.mu.Unlock()
+	return errors.Join(append(errs, publishAll(j.BaseNode, outs))...)
+}
+
+func (j *Join) process(msg *node.Message) (*node.Message, error) {
+	s, err := j.side(msg)
+	if err != nil {
+		return nil, err
+	}
+	keyFn := j.cfg.Key
+	if s == right && j.cfg.RightKey != nil {
+		keyFn = j.cfg.RightKey
+	}
+	key := keyFn.key(msg.Payload)
+	at := msg.Timestamp
+	if j.cfg.EventTime != nil {
+		at = j.cfg.EventTime(msg)
+	}
+
+	j.mu.Lock()
+	outs, err := j.insert(s, key, &joinEntry{msg: msg, at: at})
+	j.mu.Unlock()
+	if err != nil {
+		// Forwarding skips the output of failed events; publish the
+		// results directly so matches are not lost.
+		return nil, errors.Join(err, publishAll(j.BaseNode, outs))
+	}
+	return node.NewBatch(outs...), nil
+}
+
+// insert buffers an event on side s and returns its matches and the
+// results of the events it evicts. j.mu must be held.
+func (j *Join) insert(s int, key string, e *joinEntry) ([]*node.Message, error) {
+	now := time.Now()
+	e.expires = now.Add(j.cfg.TTL)
+	var (
+		outs []*node.Message
+		errs []error
+	)
+	add := func(out *node.Message, err error) {
+		if out != nil {
+			outs = append(outs, out)
+		}
+		errs = append(errs, err)
+	}
+	for _, side := range []int{left, right} {
+		evicted, err := j.expire(side, key, now)
+		outs, errs = append(outs, evicted...), append(errs, err)
+	}
+	for _, other := range j.sides[1-s][key] {
+		l, r := e, other
+		if s == right {
+			l, r = other, e
+		}
+		if d := r.at.Sub(l.at); d < j.lower || d > j.upper {
+			continue
+		}
+		l.matched = true
+		add(j.result(key, l, r, e.msg))
+	}
+
+	buffered := append(j.sides[s][key], e)
+	if len(buffered) > j.cfg.MaxPerKey {
+		add(j.evict(s, key, buffered[0]))
+		buffered = buffered[1:]
+	}
+	j.sides[s][key] = buffered
+	return outs, errors.Join(errs...)
+}
+
+// side returns the side of the join msg is on.
+func (j *Join) side(msg *node.Message) (int, error) {
+	switch v := msg.Header(HeaderJoinSide); {
+	case v == "left":
+		return left, nil
+	case v == "right":
+		return right, nil
+	case v != "":
+		return 0, fmt.Errorf("stream: join %s: invalid %s header %q: %w", j.GetID(), HeaderJoinSide, v, node.ErrPermanent)
+	}
+	switch msg.Source {
+	case j.cfg.Left:
+		return left, nil
+	case j.cfg.Right:
+		return right, nil
+	}
+	return 0, fmt.Errorf("stream: join %s: source %q: %w", j.GetID(), msg.Source, node.Permanent(ErrUnknownSide))
+}
+
+// expire evicts the events of a key and side whose TTL passed before now
+// and returns the resulting output. j.mu must be held.
+func (j *Join) expire(s int, key string, now time.Time) ([]*node.Message, error) {
+	entries := j.sides[s][key]
+	var (
+		outs []*node.Message
+		errs []error
+	)
+	for len(entries) > 0 && !entries[0].expires.After(now) {
+		out, err := j.evict(s, key, entries[0])
+		if out != nil {
+			outs = append(outs, out)
+		}
+		errs = append(errs, err)
+		entries = entries[1:]
+	}
+	if len(entries) == 0 {
+		delete(j.sides[s], key)
+	} else {
+		j.sides[s][key] = entries
+	}
+	return outs, errors.Join(errs...)
+}
+
+// evict accounts for an event leaving the buffer and returns its result if
+// it is an unmatched left event of a left-outer join. j.mu must be held.
+func (j *Join) evict(s int, key string, e *joinEntry) (*node.Message, error) {
+	j.evicted++
+	if s == left && j.outer && !e.matched {
+		return j.result(key, e, nil, e.msg)
+	}
+	return nil, nil
+}
+
+// flush publishes the unmatched left events of a left-outer join.
+func (j *Join) flush() error {
+	j.mu.Lock()
+	var (
+		outs []*node.Message
+		errs []error
+	)
+	if j.outer {
+		for key, entries := range j.sides[left] {
+			for _, e := range entries {
+				if e.matched {
+					continue
+				}
+				out, err := j.result(key, e, nil, e.msg)
+				if out != nil {
+					outs = append(outs, out)
+				}
+				errs = append(errs, err)
+			}
+		}
+	}
+	j.sides = [2]map[string][]*joinEntry{make(map[string][]*joinEntry), make(map[string][]*joinEntry)}
+	j.mu.Unlock()
+	return errors.Join(append(errs, publishAll(j.BaseNode, outs))...)
+}
+
+// result returns the output for a pair, derived from parent. r is nil for
+// unmatched left events. j.mu must be held.
+func (j *Join) result(key string, l, r *joinEntry, parent *node.Message) (*node.Message, error) {
+	var rightPayload []byte
+	at := l.at
+	if r != nil {
+		rightPayload = r.msg.Payload
+		if r.at.After(at) {
+			at = r.at
+		}
+	}
+	var (
+		payload []byte
+		err     error
+	)
+	if j.cfg.Combine != nil {
+		payload, err = j.cfg.Combine(l.msg.Payload, rightPayload)
+	} else {
+		result := JoinResult{Key: key, Left: rawJSON(l.msg.Payload)}
+		if r != nil {
+			result.Right = rawJSON(rightPayload)
+		}
+		payload, err = json.Marshal(result)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stream: join %s: key %q: %w", j.GetID(), key, err)
+	}
+	out := parent.Derive(payload)
+	out.Timestamp = at
+	return out, nil
+}
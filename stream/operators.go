@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// Map creates a node that emits fn applied to each event. Errors from fn
+// are returned and nothing is emitted.
+func Map(id string, fn node.ProcessFunc) *node.BaseNode {
+	n := newOperator(id)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		out, err := fn(msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		return msg.Derive(out), nil
+	})
+	return n
+}
+
+// Filter creates a node that emits the events for which pred returns true
+// and drops the others, returning a nil output for them.
+func Filter(id string, pred node.Filter) *node.BaseNode {
+	n := newOperator(id)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		if !pred(msg.Payload) {
+			return nil, nil
+		}
+		return msg.Derive(msg.Payload), nil
+	})
+	return n
+}
+
+// FlatMap creates a node that emits every payload fn returns for an event,
+// in order. The output is a batch of the payloads.
+func FlatMap(id string, fn func(payload []byte) ([][]byte, error)) *node.BaseNode {
+	n := newOperator(id)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		outs, err := fn(msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		msgs := make([]*node.Message, len(outs))
+		for i, out := range outs {
+			msgs[i] = msg.Derive(out)
+		}
+		return node.NewBatch(msgs...), nil
+	})
+	return n
+}
+
+// Scan creates a node that folds each event into an accumulator starting
+// at initial and emits the new accumulator. If fn fails the accumulator is
+// left unchanged.
+func Scan(id string, initial []byte, fn func(acc, payload []byte) ([]byte, error)) *node.BaseNode {
+	n := newOrderedOperator(id)
+	acc := initial
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		next, err := fn(acc, msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		acc = next
+		return msg.Derive(acc), nil
+	})
+	return n
+}
+
+// Reduce creates a node that folds each event into an accumulator starting
+// at initial and emits the final accumulator once, when the node is
+// deleted. It returns a nil output for every event.
+func Reduce(id string, initial []byte, fn func(acc, payload []byte) ([]byte, error)) *node.BaseNode {
+	n := newOperator(id)
+	var (
+		mu   sync.Mutex
+		acc  = initial
+		last *node.Message
+	)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		next, err := fn(acc, msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		acc, last = next, msg
+		return nil, nil
+	})
+	n.OnStop(func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if last == nil {
+			last = node.NewMessage(id, nil)
+		}
+		return publish(n, last, acc)
+	})
+	return n
+}
+
+// Distinct creates a node that emits only events whose key it has not seen
+// before. It remembers the capacity most recent keys, or every key if
+// capacity is zero. Duplicates get a nil output.
+func Distinct(id string, key KeyFunc, capacity int) *node.BaseNode {
+	n := newOrderedOperator(id)
+	var (
+		seen  = make(map[string]struct{})
+		order []string // keys in the order they were seen, when bounded
+	)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		k := key.key(msg.Payload)
+		if _, dup := seen[k]; dup {
+			return nil, nil
+		}
+		seen[k] = struct{}{}
+		if capacity > 0 {
+			order = append(order, k)
+			if len(order) > capacity {
+				delete(seen, order[0])
+				order = order[1:]
+			}
+		}
+		return msg.Derive(msg.Payload), nil
+	})
+	return n
+}
+
+// BufferCount creates a node that collects events into batches of size and
+// emits each full batch joined with join, or JoinJSON if join is nil.
+// Events are buffered with a nil output; a partial batch is emitted when
+// the node is deleted.
+func BufferCount(id string, size int, join JoinFunc) *node.BaseNode {
+	if join == nil {
+		join = JoinJSON
+	}
+	n := newOrderedOperator(id)
+	b := &batch{n: n, join: join}
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.add(msg)
+		if len(b.payloads) < size {
+			return nil, nil
+		}
+		return b.flush()
+	})
+	n.OnStop(b.flushLocked)
+	return n
+}
+
+// batch collects events for the buffering operators.
+type batch struct {
+	n        *node.BaseNode
+	join     JoinFunc
+	mu       sync.Mutex
+	payloads [][]byte
+	last     *node.Message
+}
+
+// add appends msg to the batch. b.mu must be held.
+func (b *batch) add(msg *node.Message) {
+	b.payloads = append(b.payloads, msg.Payload)
+	b.last = msg
+}
+
+// flush returns the batch as one message, or nil if it is empty, and
+// resets it. b.mu must be held.
+func (b *batch) flush() (*node.Message, error) {
+	if len(b.payloads) == 0 {
+		return nil, nil
+	}
+	payload, err := b.join(b.payloads)
+	last := b.last
+	b.payloads, b.last = nil, nil
+	if err != nil {
+		return nil, err
+	}
+	return last.Derive(payload), nil
+}
+
+// flushLocked flushes the batch under its lock and publishes it.
+func (b *batch) flushLocked() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out, err := b.flush()
+	if out == nil {
+		return err
+	}
+	return b.n.NotifyMessage(out)
+}
@@ -0,0 +1,152 @@
+// Package stream provides ready-made stream operators as nodes.
+//
+// Each constructor returns a node whose process function applies the
+// operator and returns what it emits for the input, which the node forwards
+// to its subscribers:
+//
+//	parse := stream.Map("parse", parseReading)
+//	hot := stream.Filter("hot", isHot)
+//	quiet := stream.Debounce("quiet", time.Second)
+//	parse.Subscribe(hot)
+//	hot.Subscribe(quiet)
+//
+// Operators enable forwarding when they are created, so they work on their
+// own and as Pipeline stages alike; inputs that emit nothing get a nil
+// output, and inputs that emit several events get a node.NewBatch output.
+// Events emitted later, by a timer or when the node is deleted, are
+// published directly. Emitted messages are derived from the input that
+// produced them and keep its headers. Delivery errors of events emitted in
+// response to an input are returned from Process and ProcessMessage; those
+// of events emitted later are handled by the node's retry policies and
+// dead-letter sink only.
+//
+// Concurrency contract: every operator may be called from concurrent Notify
+// deliveries. Stateless operators (Map, Filter, FlatMap) run concurrently
+// and do not order their outputs across inputs. Scan, Distinct,
+// BufferCount, windows and joins process inputs, and forward their output,
+// one at a time in the order they arrive, so a slow subscriber holds them
+// up and they must not be subscribed to themselves, directly or through a
+// cycle. Time operators (Debounce, Throttle, Sample, BufferTime) measure
+// time with the wall clock, start their timers when the node is created and
+// stop them, flushing pending events where noted, when it is deleted.
+//
+// Window nodes (Tumbling, Sliding and Session) group events by key and by
+// event time rather than arrival time. They track a watermark to decide
+// when a window is complete, so out-of-order events are still counted in
+// the right window.
+//
+// Join nodes (InnerJoin, LeftJoin and IntervalJoin) correlate two inputs by
+// key within a time window. They tell their inputs apart by the Source of
+// each message, which NotifyMessage sets to the publishing node's ID.
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// KeyFunc extracts the key of an event. A nil KeyFunc uses the whole
+// payload as the key.
+type KeyFunc func(payload []byte) string
+
+// key applies fn, defaulting to the payload.
+func (fn KeyFunc) key(payload []byte) string {
+	if fn == nil {
+		return string(payload)
+	}
+	return fn(payload)
+}
+
+// JSONKey returns a KeyFunc reading the field name of a JSON object. Events
+// that are not JSON objects, or lack the field, get the empty key.
+func JSONKey(name string) KeyFunc {
+	return func(payload []byte) string {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(payload, &obj); err != nil {
+			return ""
+		}
+		switch v := obj[name].(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+}
+
+// JoinFunc combines the payloads of a batch into one payload.
+type JoinFunc func(payloads [][]byte) ([]byte, error)
+
+// JoinJSON joins JSON payloads into a JSON array. It fails if a payload is
+// not valid JSON.
+func JoinJSON(payloads [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, p := range payloads {
+		if !json.Valid(p) {
+			return nil, fmt.Errorf("stream: batch item %d is not valid JSON", i)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(p)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// JoinLines joins payloads with newlines.
+func JoinLines(payloads [][]byte) ([]byte, error) {
+	return bytes.Join(payloads, []byte("\n")), nil
+}
+
+// rawJSON returns b if it is valid JSON and b encoded as a JSON string
+// otherwise. A nil b encodes as null.
+func rawJSON(b []byte) json.RawMessage {
+	if b == nil || json.Valid(b) {
+		return b
+	}
+	s, _ := json.Marshal(string(b))
+	return s
+}
+
+// newOperator creates a node that forwards the output of its process
+// function to its subscribers.
+func newOperator(id string) *node.BaseNode {
+	n := node.NewBaseNode(id)
+	n.SetForwarding(node.ForwardConfig{})
+	return n
+}
+
+// newOrderedOperator creates a node like newOperator that processes
+// messages, and forwards their output, one at a time in the order they
+// arrive.
+func newOrderedOperator(id string) *node.BaseNode {
+	n := node.NewPartitionedNode(id, 1, nil, nil).BaseNode
+	n.SetForwarding(node.ForwardConfig{})
+	return n
+}
+
+// publish publishes payload as a message derived from parent. It is used
+// for output produced outside of processing, by timers and when the node
+// is deleted.
+func publish(n *node.BaseNode, parent *node.Message, payload []byte) error {
+	return n.NotifyMessage(parent.Derive(payload))
+}
+
+// publishAll publishes msgs in order.
+func publishAll(n *node.BaseNode, msgs []*node.Message) error {
+	var errs []error
+	for _, msg := range msgs {
+		if err := n.NotifyMessage(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,68 @@
+package stream_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// collector records the payloads a node emits.
+type collector struct {
+	*node.BaseNode
+	mu   sync.Mutex
+	seen []string
+}
+
+// collect starts n and a collector subscribed to it. Both are deleted when
+// the test ends.
+func collect(t *testing.T, n *node.BaseNode) *collector {
+	t.Helper()
+	c := &collector{BaseNode: node.NewBaseNode(n.GetID() + "-out")}
+	c.SetProcessFunc(func(input []byte) ([]byte, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.seen = append(c.seen, string(input))
+		return input, nil
+	})
+	for _, n := range []*node.BaseNode{c.BaseNode, n} {
+		if err := n.Create(); err != nil {
+			t.Fatalf("%s: Create() error = %v", n.GetID(), err)
+		}
+	}
+	n.Subscribe(c)
+	t.Cleanup(func() {
+		n.Delete()
+		c.Delete()
+	})
+	return c
+}
+
+func (c *collector) payloads() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.seen...)
+}
+
+// waitFor polls until the collector has received want payloads.
+func (c *collector) waitFor(t *testing.T, want int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := c.payloads()
+		if len(got) >= want || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func send(t *testing.T, n *node.BaseNode, payloads ...string) {
+	t.Helper()
+	for _, p := range payloads {
+		if _, err := n.Process([]byte(p)); err != nil {
+			t.Fatalf("%s: Process(%q) error = %v", n.GetID(), p, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package stream_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+	"github.com/lhemerly/Constellation/stream"
+)
+
+// joinFixture starts a join fed by an orders node on the left and a
+// payments node on the right.
+func joinFixture(t *testing.T, j *stream.Join) (orders, payments *node.BaseNode, c *collector) {
+	t.Helper()
+	orders, payments = node.NewBaseNode("orders"), node.NewBaseNode("payments")
+	c = collect(t, j.BaseNode)
+	for _, n := range []*node.BaseNode{orders, payments} {
+		if err := n.Create(); err != nil {
+			t.Fatal(err)
+		}
+		n.Subscribe(j)
+	}
+	return orders, payments, c
+}
+
+// publish sends an event for order id from n at epoch plus offset.
+func publish(t *testing.T, n *node.BaseNode, id string, offset time.Duration) {
+	t.Helper()
+	msg := node.NewMessage(n.GetID(), []byte(fmt.Sprintf(`{"order":%q,"from":%q}`, id, n.GetID())))
+	msg.Timestamp = epoch.Add(offset)
+	if err := n.NotifyMessage(msg); err != nil {
+		t.Fatalf("%s: NotifyMessage(%s) error = %v", n.GetID(), id, err)
+	}
+}
+
+func joinResults(t *testing.T, c *collector) []stream.JoinResult {
+	t.Helper()
+	var out []stream.JoinResult
+	for _, p := range c.payloads() {
+		var r stream.JoinResult
+		if err := json.Unmarshal([]byte(p), &r); err != nil {
+			t.Fatalf("invalid join result %q: %v", p, err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+var orderConfig = stream.JoinConfig{Left: "orders", Right: "payments", Key: stream.JSONKey("order")}
+
+func TestInnerJoin(t *testing.T) {
+	j := stream.InnerJoin("join", time.Minute, orderConfig)
+	orders, payments, c := joinFixture(t, j)
+
+	publish(t, orders, "o1", 0)
+	publish(t, payments, "o2", 0)
+	publish(t, payments, "o1", 30*time.Second)
+	publish(t, payments, "o1", 5*time.Minute) // outside the window
+
+	got := joinResults(t, c)
+	if len(got) != 1 || got[0].Key != "o1" ||
+		string(got[0].Left) != `{"order":"o1","from":"orders"}` ||
+		string(got[0].Right) != `{"order":"o1","from":"payments"}` {
+		t.Errorf("results = %+v", got)
+	}
+	if j.Buffered() != 4 {
+		t.Errorf("Buffered() = %d, want 4", j.Buffered())
+	}
+}
+
+func TestLeftJoinEmitsUnmatchedOnEviction(t *testing.T) {
+	cfg := orderConfig
+	cfg.TTL = 30 * time.Millisecond
+	j := stream.LeftJoin("left", time.Minute, cfg)
+	orders, payments, c := joinFixture(t, j)
+
+	publish(t, orders, "o1", 0)
+	publish(t, orders, "o2", 0)
+	publish(t, payments, "o1", time.Second)
+
+	c.waitFor(t, 2)
+	results := joinResults(t, c)
+	if len(results) != 2 || results[0].Key != "o1" || results[1].Key != "o2" || string(results[1].Right) != "null" {
+		t.Errorf("results = %+v", results)
+	}
+	for deadline := time.Now().Add(time.Second); j.Buffered() > 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if j.Buffered() != 0 || j.EvictedCount() != 3 {
+		t.Errorf("Buffered() = %d, EvictedCount() = %d, want 0 and 3", j.Buffered(), j.EvictedCount())
+	}
+}
+
+func TestIntervalJoin(t *testing.T) {
+	j := stream.IntervalJoin("interval", 0, time.Hour, orderConfig)
+	orders, payments, c := joinFixture(t, j)
+
+	publish(t, payments, "o1", -time.Minute) // before the order
+	publish(t, payments, "o1", 10*time.Minute)
+	publish(t, orders, "o1", 0) // arrives after its payments
+
+	got := joinResults(t, c)
+	if len(got) != 1 || string(got[0].Right) != `{"order":"o1","from":"payments"}` {
+		t.Errorf("results = %+v", got)
+	}
+}
+
+func TestIntervalJoinZeroWidth(t *testing.T) {
+	j := stream.IntervalJoin("exact", 0, 0, orderConfig)
+	orders, payments, c := joinFixture(t, j)
+
+	publish(t, orders, "o1", 0)
+	publish(t, payments, "o1", time.Second) // outside the window
+	publish(t, payments, "o1", 0)
+
+	if got := joinResults(t, c); len(got) != 1 || got[0].Key != "o1" {
+		t.Errorf("results = %+v, want the events at the same time matched", got)
+	}
+}
+
+func TestJoinRejectsInvalidBounds(t *testing.T) {
+	for _, j := range []*stream.Join{
+		stream.IntervalJoin("inverted", time.Hour, 0, orderConfig),
+		stream.InnerJoin("negative", -time.Minute, orderConfig),
+		stream.LeftJoin("negative-left", -time.Minute, orderConfig),
+	} {
+		if err := j.Create(); !errors.Is(err, stream.ErrInvalidBounds) {
+			t.Errorf("%s: Create() error = %v, want ErrInvalidBounds", j.GetID(), err)
+		}
+	}
+}
+
+func TestJoinPublishesOutsideLock(t *testing.T) {
+	cfg := orderConfig
+	cfg.TTL = 20 * time.Millisecond
+	j := stream.LeftJoin("left", time.Minute, cfg)
+	orders, _, _ := joinFixture(t, j)
+	// A subscriber that reads the join while handling its results would
+	// deadlock if they were published with the join locked.
+	reader := node.NewBaseNode("reader")
+	got := make(chan int, 2)
+	reader.SetProcessFunc(func(input []byte) ([]byte, error) {
+		got <- j.Buffered()
+		return input, nil
+	})
+	if err := reader.Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Delete()
+	j.Subscribe(reader)
+
+	publish(t, orders, "swept", 0)
+	select {
+	case <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Sweep did not publish the unmatched event")
+	}
+	publish(t, orders, "flushed", 0)
+	done := make(chan error, 1)
+	go func() { done <- j.Delete() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("join published results while locked")
+	}
+	if len(got) != 1 {
+		t.Errorf("reader got %d flushed results, want 1", len(got))
+	}
+}
+
+func TestJoinBufferingAndSides(t *testing.T) {
+	cfg := orderConfig
+	cfg.MaxPerKey = 2
+	cfg.Combine = func(l, r []byte) ([]byte, error) {
+		return []byte(stream.JSONKey("from")(l) + "+" + stream.JSONKey("from")(r)), nil
+	}
+	j := stream.InnerJoin("bounded", time.Hour, cfg)
+	orders, _, c := joinFixture(t, j)
+
+	for i := 0; i < 3; i++ {
+		publish(t, orders, "o1", time.Duration(i)*time.Second)
+	}
+	if j.Buffered() != 2 || j.EvictedCount() != 1 {
+		t.Errorf("Buffered() = %d, EvictedCount() = %d, want 2 and 1", j.Buffered(), j.EvictedCount())
+	}
+
+	// The side header overrides the source.
+	msg := node.NewMessage("elsewhere", []byte(`{"order":"o1","from":"header"}`))
+	msg.Timestamp = epoch
+	msg.SetHeader(stream.HeaderJoinSide, "right")
+	if _, err := j.ProcessMessage(msg); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if got := c.payloads(); len(got) != 2 || got[0] != "orders+header" {
+		t.Errorf("emitted %q, want two matches", got)
+	}
+
+	_, err := j.ProcessMessage(node.NewMessage("elsewhere", []byte("{}")))
+	if !errors.Is(err, stream.ErrUnknownSide) || node.IsRetryable(err) {
+		t.Errorf("unknown source error = %v, want permanent ErrUnknownSide", err)
+	}
+}
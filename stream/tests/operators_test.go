@@ -0,0 +1,155 @@
+package stream_test
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lhemerly/Constellation/stream"
+)
+
+func TestMapFilterFlatMap(t *testing.T) {
+	upper := stream.Map("upper", func(in []byte) ([]byte, error) {
+		if len(in) == 0 {
+			return nil, errors.New("empty")
+		}
+		return bytes.ToUpper(in), nil
+	})
+	mapped := collect(t, upper)
+	out, err := upper.Process([]byte("a"))
+	if err != nil || string(out) != "A" {
+		t.Errorf("Map Process() = %q, %v", out, err)
+	}
+	if _, err := upper.Process(nil); err == nil {
+		t.Error("Map Process() with failing fn error = nil")
+	}
+
+	even := stream.Filter("even", func(in []byte) bool {
+		v, _ := strconv.Atoi(string(in))
+		return v%2 == 0
+	})
+	filtered := collect(t, even)
+	send(t, even, "1", "2", "3", "4")
+
+	split := stream.FlatMap("split", func(in []byte) ([][]byte, error) {
+		return bytes.Fields(in), nil
+	})
+	flat := collect(t, split)
+	send(t, split, "a b c", "", "d")
+
+	if got := strings.Join(mapped.payloads(), ","); got != "A" {
+		t.Errorf("Map emitted %q", got)
+	}
+	if got := strings.Join(filtered.payloads(), ","); got != "2,4" {
+		t.Errorf("Filter emitted %q", got)
+	}
+	if got := strings.Join(flat.payloads(), ","); got != "a,b,c,d" {
+		t.Errorf("FlatMap emitted %q", got)
+	}
+}
+
+func sum(acc, in []byte) ([]byte, error) {
+	a, _ := strconv.Atoi(string(acc))
+	b, err := strconv.Atoi(string(in))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strconv.Itoa(a + b)), nil
+}
+
+func TestScanIsOrderedUnderConcurrency(t *testing.T) {
+	scan := stream.Scan("sum", []byte("0"), sum)
+	c := collect(t, scan)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scan.Process([]byte(strconv.Itoa(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	got := c.payloads()
+	if len(got) != 100 || got[99] != "5050" {
+		t.Fatalf("Scan emitted %d values ending in %v", len(got), got[len(got)-1:])
+	}
+	// Running sums of positive numbers must be emitted in increasing order.
+	if !sort.SliceIsSorted(got, func(i, j int) bool {
+		a, _ := strconv.Atoi(got[i])
+		b, _ := strconv.Atoi(got[j])
+		return a < b
+	}) {
+		t.Errorf("Scan emitted out of order: %v", got)
+	}
+	if _, err := scan.Process([]byte("x")); err == nil {
+		t.Error("Scan Process() with failing fn error = nil")
+	}
+}
+
+func TestReduceEmitsOnDelete(t *testing.T) {
+	reduce := stream.Reduce("total", []byte("0"), sum)
+	c := collect(t, reduce)
+	send(t, reduce, "1", "2", "3")
+	if got := c.payloads(); len(got) != 0 {
+		t.Fatalf("Reduce emitted %q before Delete", got)
+	}
+	if err := reduce.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := c.payloads(); len(got) != 1 || got[0] != "6" {
+		t.Errorf("Reduce emitted %q, want [6]", got)
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	byPrefix := func(in []byte) string { return string(in[:1]) }
+	distinct := stream.Distinct("all", byPrefix, 0)
+	all := collect(t, distinct)
+	bounded := stream.Distinct("bounded", nil, 2)
+	recent := collect(t, bounded)
+
+	send(t, distinct, "a1", "b1", "a2", "c1", "b2")
+	send(t, bounded, "x", "y", "x", "z", "x")
+
+	if got := strings.Join(all.payloads(), ","); got != "a1,b1,c1" {
+		t.Errorf("Distinct emitted %q", got)
+	}
+	// With two keys remembered, z evicts x.
+	if got := strings.Join(recent.payloads(), ","); got != "x,y,z,x" {
+		t.Errorf("bounded Distinct emitted %q", got)
+	}
+}
+
+func TestBufferCount(t *testing.T) {
+	buf := stream.BufferCount("batch", 2, nil)
+	c := collect(t, buf)
+	send(t, buf, "1", `{"a":2}`, "3")
+	if got := c.payloads(); len(got) != 1 || got[0] != `[1,{"a":2}]` {
+		t.Errorf("BufferCount emitted %q", got)
+	}
+	if err := buf.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := c.payloads(); len(got) != 2 || got[1] != "[3]" {
+		t.Errorf("BufferCount emitted %q after Delete", got)
+	}
+
+	lines := stream.BufferCount("lines", 3, stream.JoinLines)
+	lc := collect(t, lines)
+	send(t, lines, "a", "b", "c")
+	if got := lc.payloads(); len(got) != 1 || got[0] != "a\nb\nc" {
+		t.Errorf("BufferCount with JoinLines emitted %q", got)
+	}
+
+	invalid := stream.BufferCount("invalid", 1, nil)
+	collect(t, invalid)
+	if _, err := invalid.Process([]byte("not json")); err == nil {
+		t.Error("BufferCount with invalid JSON error = nil")
+	}
+}
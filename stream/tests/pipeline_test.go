@@ -0,0 +1,55 @@
+package stream_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+	"github.com/lhemerly/Constellation/stream"
+)
+
+func TestOperatorsAsPipelineStages(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	sink := node.NewBaseNode("sink")
+	sink.SetProcessFunc(func(input []byte) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, string(input))
+		return input, nil
+	})
+	p := node.NewPipeline().
+		Source(stream.FlatMap("words", func(in []byte) ([][]byte, error) {
+			return bytes.Fields(in), nil
+		})).
+		Then(stream.Filter("long", func(in []byte) bool { return len(in) > 1 })).
+		Then(stream.Map("upper", func(in []byte) ([]byte, error) {
+			return bytes.ToUpper(in), nil
+		})).
+		Then(stream.Distinct("distinct", nil, 0)).
+		Then(stream.Scan("count", []byte("0"), func(acc, _ []byte) ([]byte, error) {
+			return sum(acc, []byte("1"))
+		})).
+		Sink(sink)
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer p.Stop(context.Background())
+
+	for _, line := range []string{"to be or not", "to be a b"} {
+		if err := p.Send([]byte(line)); err != nil {
+			t.Fatalf("Send(%q) error = %v", line, err)
+		}
+	}
+	// Every stage publishes each output exactly once.
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(seen, ","); got != "1,2,3,4" {
+		t.Errorf("sink received %q, want 1,2,3,4", got)
+	}
+}
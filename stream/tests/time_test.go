@@ -0,0 +1,108 @@
+package stream_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+	"github.com/lhemerly/Constellation/stream"
+)
+
+func TestDebounce(t *testing.T) {
+	debounce := stream.Debounce("debounce", 30*time.Millisecond)
+	c := collect(t, debounce)
+
+	var wg sync.WaitGroup
+	for _, p := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			debounce.Process([]byte(p))
+		}(p)
+	}
+	wg.Wait()
+	send(t, debounce, "last")
+	if got := c.waitFor(t, 1); len(got) != 1 || got[0] != "last" {
+		t.Fatalf("Debounce emitted %q, want [last]", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := c.payloads(); len(got) != 1 {
+		t.Errorf("Debounce emitted %q, want one event", got)
+	}
+
+	send(t, debounce, "pending")
+	if err := debounce.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := c.payloads(); len(got) != 2 || got[1] != "pending" {
+		t.Errorf("Debounce emitted %q after Delete, want the pending event", got)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	throttle := stream.Throttle("throttle", 50*time.Millisecond)
+	c := collect(t, throttle)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.Process([]byte("burst"))
+		}()
+	}
+	wg.Wait()
+	if got := c.payloads(); len(got) != 1 {
+		t.Fatalf("Throttle emitted %d events for a burst, want 1", len(got))
+	}
+	time.Sleep(60 * time.Millisecond)
+	send(t, throttle, "later")
+	if got := strings.Join(c.payloads(), ","); got != "burst,later" {
+		t.Errorf("Throttle emitted %q", got)
+	}
+}
+
+func TestSample(t *testing.T) {
+	sample := stream.Sample("sample", 20*time.Millisecond)
+	c := collect(t, sample)
+	send(t, sample, "a", "b", "c")
+	if got := c.waitFor(t, 1); len(got) != 1 || got[0] != "c" {
+		t.Fatalf("Sample emitted %q, want [c]", got)
+	}
+	// Ticks without new events emit nothing.
+	time.Sleep(60 * time.Millisecond)
+	if got := c.payloads(); len(got) != 1 {
+		t.Errorf("Sample emitted %q, want one event", got)
+	}
+}
+
+func TestBufferTime(t *testing.T) {
+	buf := stream.BufferTime("buffer", 20*time.Millisecond, stream.JoinLines)
+	c := collect(t, buf)
+	send(t, buf, "a", "b")
+	if got := c.waitFor(t, 1); len(got) != 1 || got[0] != "a\nb" {
+		t.Fatalf("BufferTime emitted %q, want one batch", got)
+	}
+	send(t, buf, "c")
+	if err := buf.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	got := c.payloads()
+	if strings.Join(got, "|") != "a\nb|c" {
+		t.Errorf("BufferTime emitted %q, want the last batch flushed on Delete", got)
+	}
+}
+
+func TestTickerRejectsNonPositiveInterval(t *testing.T) {
+	for _, n := range []*node.BaseNode{
+		stream.Sample("sample", 0),
+		stream.BufferTime("buffer", -time.Second, nil),
+	} {
+		if err := n.Create(); !errors.Is(err, stream.ErrInvalidDuration) {
+			t.Errorf("%s: Create() error = %v, want ErrInvalidDuration", n.GetID(), err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package stream_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+	"github.com/lhemerly/Constellation/stream"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// at sends an event with the given site and value at epoch plus sec
+// seconds.
+func at(t *testing.T, w *stream.Window, sec int, site string, v float64) {
+	t.Helper()
+	msg := node.NewMessage("sensor", []byte(fmt.Sprintf(`{"site":%q,"temp":%g}`, site, v)))
+	msg.Timestamp = epoch.Add(time.Duration(sec) * time.Second)
+	if _, err := w.ProcessMessage(msg); err != nil {
+		t.Fatalf("ProcessMessage(%s @%ds) error = %v", site, sec, err)
+	}
+}
+
+func results(t *testing.T, c *collector) []stream.WindowResult {
+	t.Helper()
+	var out []stream.WindowResult
+	for _, p := range c.payloads() {
+		var r stream.WindowResult
+		if err := json.Unmarshal([]byte(p), &r); err != nil {
+			t.Fatalf("invalid window result %q: %v", p, err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func bySite(payload []byte) string {
+	var v struct{ Site string }
+	json.Unmarshal(payload, &v)
+	return v.Site
+}
+
+func seconds(ts time.Time) int {
+	return int(ts.Sub(epoch) / time.Second)
+}
+
+func TestTumblingWindowOutOfOrder(t *testing.T) {
+	w := stream.Tumbling("tumbling", 10*time.Second, stream.WindowConfig{
+		Key:       bySite,
+		Aggregate: stream.Sum(stream.JSONField("temp")),
+		MaxDelay:  5 * time.Second,
+	})
+	c := collect(t, w.BaseNode)
+
+	at(t, w, 1, "a", 10)
+	at(t, w, 12, "a", 1)
+	at(t, w, 3, "a", 20) // out of order, still before the watermark
+	if got := results(t, c); len(got) != 0 {
+		t.Fatalf("emitted %+v before the watermark passed", got)
+	}
+	at(t, w, 16, "b", 5) // watermark 11 closes [0, 10)
+	got := results(t, c)
+	if len(got) != 1 || got[0].Key != "a" || got[0].Count != 2 || string(got[0].Value) != "30" ||
+		seconds(got[0].Start) != 0 || seconds(got[0].End) != 10 {
+		t.Fatalf("results = %+v", got)
+	}
+	if !w.Watermark().Equal(epoch.Add(11 * time.Second)) {
+		t.Errorf("Watermark() = %v", w.Watermark())
+	}
+
+	at(t, w, 2, "a", 100)
+	if w.LateCount() != 1 || len(results(t, c)) != 1 {
+		t.Errorf("LateCount() = %d, results = %d, want the late event dropped", w.LateCount(), len(results(t, c)))
+	}
+
+	if err := w.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	got = results(t, c)
+	if len(got) != 3 || got[1].Key != "a" || got[2].Key != "b" || string(got[2].Value) != "5" {
+		t.Errorf("results after Delete = %+v", got)
+	}
+}
+
+func TestWindowAllowedLateness(t *testing.T) {
+	w := stream.Tumbling("lateness", 10*time.Second, stream.WindowConfig{AllowedLateness: 5 * time.Second})
+	c := collect(t, w.BaseNode)
+
+	at(t, w, 1, "a", 0)
+	at(t, w, 11, "a", 0)
+	at(t, w, 2, "a", 0) // late but allowed: updates [0, 10)
+	at(t, w, 16, "a", 0)
+	at(t, w, 3, "a", 0) // [0, 10) expired at 15
+
+	got := results(t, c)
+	if len(got) != 2 || got[0].Count != 1 || got[0].Update || got[1].Count != 2 || !got[1].Update {
+		t.Errorf("results = %+v", got)
+	}
+	if w.LateCount() != 1 {
+		t.Errorf("LateCount() = %d, want 1", w.LateCount())
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	w := stream.Sliding("sliding", 10*time.Second, 5*time.Second, stream.WindowConfig{
+		Aggregate: stream.Max(stream.JSONField("temp")),
+	})
+	c := collect(t, w.BaseNode)
+
+	at(t, w, 7, "a", 3)
+	if err := w.AdvanceWatermark(epoch.Add(20 * time.Second)); err != nil {
+		t.Fatalf("AdvanceWatermark() error = %v", err)
+	}
+	got := results(t, c)
+	if len(got) != 2 || seconds(got[0].Start) != 0 || seconds(got[1].Start) != 5 || string(got[1].Value) != "3" {
+		t.Errorf("results = %+v", got)
+	}
+}
+
+func TestSessionWindowMerges(t *testing.T) {
+	w := stream.Session("session", 5*time.Second, stream.WindowConfig{
+		Key:       bySite,
+		Aggregate: stream.Min(stream.JSONField("temp")),
+		MaxDelay:  time.Minute,
+	})
+	c := collect(t, w.BaseNode)
+
+	at(t, w, 0, "a", 4)
+	at(t, w, 8, "a", 2)
+	at(t, w, 4, "a", 3) // bridges [0, 5) and [8, 13)
+	at(t, w, 1, "b", 1)
+	if err := w.AdvanceWatermark(epoch.Add(time.Hour)); err != nil {
+		t.Fatalf("AdvanceWatermark() error = %v", err)
+	}
+	got := results(t, c)
+	if len(got) != 2 || got[0].Key != "b" || got[1].Key != "a" || got[1].Count != 3 ||
+		seconds(got[1].Start) != 0 || seconds(got[1].End) != 13 || string(got[1].Value) != "2" {
+		t.Errorf("results = %+v", got)
+	}
+}
+
+func TestWindowRejectsNonPositiveDurations(t *testing.T) {
+	for _, w := range []*stream.Window{
+		stream.Tumbling("tumbling", 0, stream.WindowConfig{}),
+		stream.Sliding("sliding", 10*time.Second, 0, stream.WindowConfig{}),
+		stream.Sliding("sliding-size", -time.Second, time.Second, stream.WindowConfig{}),
+		stream.Session("session", 0, stream.WindowConfig{}),
+	} {
+		if err := w.Create(); !errors.Is(err, stream.ErrInvalidDuration) {
+			t.Errorf("%s: Create() error = %v, want ErrInvalidDuration", w.GetID(), err)
+		}
+	}
+}
+
+func TestWindowPublishesOutsideLock(t *testing.T) {
+	w := stream.Tumbling("tumbling", 10*time.Second, stream.WindowConfig{})
+	// A subscriber that reads the window while handling its results would
+	// deadlock if they were published with the window locked.
+	reader := node.NewBaseNode("reader")
+	var watermarks []time.Time
+	reader.SetProcessFunc(func(input []byte) ([]byte, error) {
+		watermarks = append(watermarks, w.Watermark())
+		return input, nil
+	})
+	for _, n := range []*node.BaseNode{reader, w.BaseNode} {
+		if err := n.Create(); err != nil {
+			t.Fatalf("%s: Create() error = %v", n.GetID(), err)
+		}
+	}
+	w.Subscribe(reader)
+	defer reader.Delete()
+
+	at(t, w, 1, "a", 1)
+	at(t, w, 11, "a", 1)
+	done := make(chan error, 1)
+	go func() {
+		err := w.AdvanceWatermark(epoch.Add(10 * time.Second))
+		done <- errors.Join(err, w.Delete())
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AdvanceWatermark() and Delete() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("window published results while locked")
+	}
+	if len(watermarks) != 2 {
+		t.Errorf("reader got %d results, want 2", len(watermarks))
+	}
+}
+
+func TestWindowWatermarkMessageAndReducer(t *testing.T) {
+	w := stream.Tumbling("reduce", time.Minute, stream.WindowConfig{
+		Aggregate: stream.Reducer(nil, func(acc, payload []byte) ([]byte, error) {
+			return append(acc, bySite(payload)...), nil
+		}),
+	})
+	c := collect(t, w.BaseNode)
+
+	at(t, w, 20, "y", 0)
+	at(t, w, 10, "x", 0) // aggregated in event-time order
+	watermark := node.NewMessage("clock", nil)
+	watermark.SetHeader(stream.HeaderWatermark, epoch.Add(time.Minute).Format(time.RFC3339Nano))
+	if _, err := w.ProcessMessage(watermark); err != nil {
+		t.Fatalf("ProcessMessage(watermark) error = %v", err)
+	}
+	got := results(t, c)
+	if len(got) != 1 || string(got[0].Value) != `"xy"` || got[0].Count != 2 {
+		t.Errorf("results = %+v", got)
+	}
+
+	bad := node.NewMessage("clock", nil)
+	bad.SetHeader(stream.HeaderWatermark, "soon")
+	if _, err := w.ProcessMessage(bad); err == nil || node.IsRetryable(err) {
+		t.Errorf("invalid watermark error = %v, want a permanent error", err)
+	}
+}
@@ -0,0 +1,183 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// Debounce creates a node that emits an event only once no newer event has
+// arrived for quiet. Superseded events are dropped. Events get a nil
+// output; a pending event is emitted when the node is deleted.
+func Debounce(id string, quiet time.Duration) *node.BaseNode {
+	n := newOperator(id)
+	var (
+		mu      sync.Mutex
+		pending *node.Message
+		timer   *time.Timer
+		gen     uint64 // identifies the timer of the latest event
+	)
+	fire := func(g uint64) {
+		mu.Lock()
+		if g != gen || pending == nil {
+			mu.Unlock()
+			return
+		}
+		msg := pending
+		pending = nil
+		mu.Unlock()
+		publish(n, msg, msg.Payload)
+	}
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		gen++
+		g := gen
+		pending = msg
+		timer = time.AfterFunc(quiet, func() { fire(g) })
+		return nil, nil
+	})
+	n.OnStop(func() error {
+		mu.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		gen++
+		msg := pending
+		pending = nil
+		mu.Unlock()
+		if msg == nil {
+			return nil
+		}
+		return publish(n, msg, msg.Payload)
+	})
+	return n
+}
+
+// Throttle creates a node that emits the first event of every interval and
+// drops the rest, returning a nil output for them.
+func Throttle(id string, interval time.Duration) *node.BaseNode {
+	n := newOperator(id)
+	var (
+		mu   sync.Mutex
+		next time.Time // when the next event may pass
+	)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		now := time.Now()
+		mu.Lock()
+		if now.Before(next) {
+			mu.Unlock()
+			return nil, nil
+		}
+		next = now.Add(interval)
+		mu.Unlock()
+		return msg.Derive(msg.Payload), nil
+	})
+	return n
+}
+
+// Sample creates a node that emits the latest event every interval, if an
+// event arrived since the previous tick. Events get a nil output. Create
+// fails with ErrInvalidDuration if interval is not positive.
+func Sample(id string, interval time.Duration) *node.BaseNode {
+	n := newOperator(id)
+	var (
+		mu     sync.Mutex
+		latest *node.Message
+	)
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		latest = msg
+		return nil, nil
+	})
+	every(n, interval, func() {
+		mu.Lock()
+		msg := latest
+		latest = nil
+		mu.Unlock()
+		if msg != nil {
+			publish(n, msg, msg.Payload)
+		}
+	})
+	return n
+}
+
+// BufferTime creates a node that collects events and emits them every
+// interval joined with join, or JoinJSON if join is nil. Nothing is
+// emitted for intervals without events. Events are buffered with a nil
+// output; the last batch is emitted when the node is deleted. Create fails
+// with ErrInvalidDuration if interval is not positive.
+func BufferTime(id string, interval time.Duration, join JoinFunc) *node.BaseNode {
+	if join == nil {
+		join = JoinJSON
+	}
+	n := newOperator(id)
+	b := &batch{n: n, join: join}
+	n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.add(msg)
+		return nil, nil
+	})
+	// Stop hooks run in reverse, so the ticker stops before the last flush.
+	n.OnStop(b.flushLocked)
+	every(n, interval, func() { b.flushLocked() })
+	return n
+}
+
+// ErrInvalidDuration is returned by Create for operators configured with a
+// duration that is not positive.
+var ErrInvalidDuration = errors.New("duration must be positive")
+
+// requirePositive makes Create fail n if d is not positive.
+func requirePositive(n *node.BaseNode, name string, d time.Duration) {
+	n.OnStart(func() error {
+		if d <= 0 {
+			return fmt.Errorf("stream: %s: %s %s: %w", n.GetID(), name, d, ErrInvalidDuration)
+		}
+		return nil
+	})
+}
+
+// every calls tick every interval while n is running, which must be
+// positive. The ticker starts with the node's OnStart hooks and stops,
+// waiting for a tick in progress, with its OnStop hooks.
+func every(n *node.BaseNode, interval time.Duration, tick func()) {
+	requirePositive(n, "interval", interval)
+	var (
+		stop chan struct{}
+		done chan struct{}
+	)
+	n.OnStart(func() error {
+		stop, done = make(chan struct{}), make(chan struct{})
+		go func(stop, done chan struct{}) {
+			defer close(done)
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					tick()
+				case <-stop:
+					return
+				}
+			}
+		}(stop, done)
+		return nil
+	})
+	n.OnStop(func() error {
+		if stop != nil {
+			close(stop)
+			<-done
+			stop = nil
+		}
+		return nil
+	})
+}
@@ -0,0 +1,395 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// HeaderWatermark marks a message that only advances a window's watermark
+// to the RFC 3339 time it carries. Such messages are not events.
+const HeaderWatermark = "watermark"
+
+// WindowConfig configures a window node.
+type WindowConfig struct {
+	// Key groups events into independent windows. If nil, all events
+	// share one group.
+	Key KeyFunc
+	// Aggregate computes each window's value. If nil, windows count their
+	// events.
+	Aggregate Aggregator
+	// EventTime returns the time of an event. If nil, the message
+	// Timestamp is used.
+	EventTime func(msg *node.Message) time.Time
+	// MaxDelay is how far behind the latest event time the watermark
+	// trails, that is how out of order events may arrive and still be
+	// counted before their window is emitted.
+	MaxDelay time.Duration
+	// AllowedLateness keeps windows open after they are emitted. Events
+	// arriving within it update the window, which is emitted again with
+	// Update set; later events are dropped.
+	AllowedLateness time.Duration
+}
+
+// WindowResult is the payload emitted for a window, encoded as JSON.
+type WindowResult struct {
+	Key   string    `json:"key"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Count int       `json:"count"`
+	// Value is the aggregate, or a JSON string holding it if the
+	// aggregator did not return JSON.
+	Value json.RawMessage `json:"value"`
+	// Update is set when a late event changed a window already emitted.
+	Update bool `json:"update,omitempty"`
+}
+
+// Window is a node that groups events by key and event time and emits an
+// aggregate for each window once the watermark passes its end. The
+// watermark is the latest event time seen minus MaxDelay; it only moves
+// forward, on events, on HeaderWatermark messages and on AdvanceWatermark.
+//
+// A window processes events one at a time and emits its results, ordered
+// by window end and key, as the output of the event that completed them: a
+// batch of results, or nil if there are none. Results of AdvanceWatermark,
+// and of the open windows emitted when the node is deleted, are published
+// directly. Windows keep the payloads of their events until they expire, so
+// the aggregator sees them in event-time order whatever order they arrived
+// in.
+type Window struct {
+	*node.BaseNode
+	cfg    WindowConfig
+	assign func(t time.Time) []span // nil for session windows
+	gap    time.Duration
+
+	mu        sync.Mutex
+	watermark time.Time
+	open      map[string][]*window
+	late      uint64
+}
+
+// Tumbling creates a window node with fixed, non-overlapping windows of
+// size aligned to the zero time. Create fails with ErrInvalidDuration if
+// size is not positive.
+func Tumbling(id string, size time.Duration, cfg WindowConfig) *Window {
+	w := newWindow(id, cfg, func(t time.Time) []span {
+		start := t.Truncate(size)
+		return []span{{start, start.Add(size)}}
+	}, 0)
+	requirePositive(w.BaseNode, "size", size)
+	return w
+}
+
+// Sliding creates a window node with windows of size starting every slide,
+// so each event belongs to size/slide windows. Create fails with
+// ErrInvalidDuration if size or slide is not positive.
+func Sliding(id string, size, slide time.Duration, cfg WindowConfig) *Window {
+	w := newWindow(id, cfg, func(t time.Time) []span {
+		var spans []span
+		for start := t.Truncate(slide); start.After(t.Add(-size)); start = start.Add(-slide) {
+			spans = append(spans, span{start, start.Add(size)})
+		}
+		return spans
+	}, 0)
+	requirePositive(w.BaseNode, "size", size)
+	requirePositive(w.BaseNode, "slide", slide)
+	return w
+}
+
+// Session creates a window node whose windows close after gap without
+// events for their key. An event that bridges two sessions merges them.
+// Create fails with ErrInvalidDuration if gap is not positive.
+func Session(id string, gap time.Duration, cfg WindowConfig) *Window {
+	w := newWindow(id, cfg, nil, gap)
+	requirePositive(w.BaseNode, "gap", gap)
+	return w
+}
+
+func newWindow(id string, cfg WindowConfig, assign func(time.Time) []span, gap time.Duration) *Window {
+	if cfg.Aggregate == nil {
+		cfg.Aggregate = Count
+	}
+	w := &Window{
+		BaseNode: newOrderedOperator(id),
+		cfg:      cfg,
+		assign:   assign,
+		gap:      gap,
+		open:     make(map[string][]*window),
+	}
+	w.SetMessageFunc(w.process)
+	w.OnStop(w.flush)
+	return w
+}
+
+// Watermark returns the window's current watermark.
+func (w *Window) Watermark() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.watermark
+}
+
+// LateCount returns the number of events dropped because they arrived
+// after their windows expired.
+func (w *Window) LateCount() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.late
+}
+
+// AdvanceWatermark moves the watermark to t, if it is later, and publishes
+// the windows it completes. Use it when sources go idle.
+func (w *Window) AdvanceWatermark(t time.Time) error {
+	w.mu.Lock()
+	outs, err := w.advance(t)
+	w.mu.Unlock()
+	return errors.Join(err, publishAll(w.BaseNode, outs))
+}
+
+// span is the time range [start, end) of a window.
+type span struct {
+	start, end time.Time
+}
+
+// window is the state of an open window.
+type window struct {
+	span
+	key     string
+	events  []windowEvent
+	emitted bool
+}
+
+type windowEvent struct {
+	at  time.Time
+	msg *node.Message
+}
+
+// add inserts an event, keeping events in event-time order.
+func (win *window) add(e windowEvent) {
+	i := sort.Search(len(win.events), func(i int) bool { return win.events[i].at.After(e.at) })
+	win.events = append(win.events, windowEvent{})
+	copy(win.events[i+1:], win.events[i:])
+	win.events[i] = e
+}
+
+func (w *Window) process(msg *node.Message) (*node.Message, error) {
+	if v := msg.Header(HeaderWatermark); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, fmt.Errorf("stream: window %s: invalid watermark %q: %w", w.GetID(), v, node.ErrPermanent)
+		}
+		w.mu.Lock()
+		outs, err := w.advance(t)
+		w.mu.Unlock()
+		return w.output(outs, err)
+	}
+
+	at := msg.Timestamp
+	if w.cfg.EventTime != nil {
+		at = w.cfg.EventTime(msg)
+	}
+	key := ""
+	if w.cfg.Key != nil {
+		key = w.cfg.Key(msg.Payload)
+	}
+	w.mu.Lock()
+	outs, err := w.insert(key, windowEvent{at, msg})
+	w.mu.Unlock()
+	return w.output(outs, err)
+}
+
+// insert assigns an event to its windows and returns the results it updates
+// or completes. w.mu must be held.
+func (w *Window) insert(key string, e windowEvent) ([]*node.Message, error) {
+	at := e.at
+	var updated []*window
+	if w.assign != nil {
+		for _, s := range w.assign(at) {
+			if w.expired(s) {
+				continue
+			}
+			win := w.find(key, s)
+			win.add(e)
+			updated = append(updated, win)
+		}
+	} else if s := (span{at, at.Add(w.gap)}); !w.expired(s) {
+		updated = append(updated, w.merge(key, s, e))
+	}
+	if len(updated) == 0 {
+		w.late++
+		return nil, nil
+	}
+
+	var (
+		outs []*node.Message
+		errs []error
+	)
+	for _, win := range updated {
+		if win.emitted {
+			out, err := w.result(win, true)
+			if out != nil {
+				outs = append(outs, out)
+			}
+			errs = append(errs, err)
+		}
+	}
+	due, err := w.advance(at.Add(-w.cfg.MaxDelay))
+	return append(outs, due...), errors.Join(append(errs, err)...)
+}
+
+// output returns results as the output of an event. Forwarding skips the
+// output of failed events, so if err is set the results are published
+// directly instead, to not lose windows already marked emitted. w.mu must
+// not be held.
+func (w *Window) output(outs []*node.Message, err error) (*node.Message, error) {
+	if err != nil {
+		return nil, errors.Join(err, publishAll(w.BaseNode, outs))
+	}
+	return node.NewBatch(outs...), nil
+}
+
+// expired reports whether a window with span s can no longer accept
+// events. w.mu must be held.
+func (w *Window) expired(s span) bool {
+	return !s.end.Add(w.cfg.AllowedLateness).After(w.watermark)
+}
+
+// find returns the open window with span s, creating it if needed. w.mu
+// must be held.
+func (w *Window) find(key string, s span) *window {
+	for _, win := range w.open[key] {
+		if win.start.Equal(s.start) && win.end.Equal(s.end) {
+			return win
+		}
+	}
+	win := &window{span: s, key: key}
+	w.open[key] = append(w.open[key], win)
+	return win
+}
+
+// merge adds an event to the session it falls into, merging the sessions
+// it bridges. w.mu must be held.
+func (w *Window) merge(key string, s span, e windowEvent) *window {
+	merged := &window{span: s, key: key}
+	var rest []*window
+	for _, win := range w.open[key] {
+		if !win.start.Before(s.end) || !win.end.After(s.start) {
+			rest = append(rest, win)
+			continue
+		}
+		if win.start.Before(merged.start) {
+			merged.start = win.start
+		}
+		if win.end.After(merged.end) {
+			merged.end = win.end
+		}
+		merged.emitted = merged.emitted || win.emitted
+		for _, old := range win.events {
+			merged.add(old)
+		}
+	}
+	merged.add(e)
+	w.open[key] = append(rest, merged)
+	return merged
+}
+
+// advance moves the watermark to t, if it is later, returns the results of
+// the windows it completes and drops those it expires. w.mu must be held.
+func (w *Window) advance(t time.Time) ([]*node.Message, error) {
+	if t.After(w.watermark) {
+		w.watermark = t
+	}
+	var due []*window
+	for key, wins := range w.open {
+		kept := wins[:0]
+		for _, win := range wins {
+			if !win.emitted && !win.end.After(w.watermark) {
+				due = append(due, win)
+			}
+			if !w.expired(win.span) {
+				kept = append(kept, win)
+			}
+		}
+		if len(kept) == 0 {
+			delete(w.open, key)
+		} else {
+			w.open[key] = kept
+		}
+	}
+	return w.results(due)
+}
+
+// flush publishes every window not emitted yet.
+func (w *Window) flush() error {
+	w.mu.Lock()
+	var due []*window
+	for _, wins := range w.open {
+		for _, win := range wins {
+			if !win.emitted {
+				due = append(due, win)
+			}
+		}
+	}
+	w.open = make(map[string][]*window)
+	outs, err := w.results(due)
+	w.mu.Unlock()
+	return errors.Join(err, publishAll(w.BaseNode, outs))
+}
+
+// results returns the results of windows ordered by end and key. w.mu must
+// be held.
+func (w *Window) results(wins []*window) ([]*node.Message, error) {
+	sort.Slice(wins, func(i, j int) bool {
+		if !wins[i].end.Equal(wins[j].end) {
+			return wins[i].end.Before(wins[j].end)
+		}
+		if wins[i].key != wins[j].key {
+			return wins[i].key < wins[j].key
+		}
+		return wins[i].start.Before(wins[j].start)
+	})
+	var (
+		outs []*node.Message
+		errs []error
+	)
+	for _, win := range wins {
+		out, err := w.result(win, false)
+		if out != nil {
+			outs = append(outs, out)
+		}
+		errs = append(errs, err)
+	}
+	return outs, errors.Join(errs...)
+}
+
+// result marks a window emitted and returns its result. w.mu must be held.
+func (w *Window) result(win *window, update bool) (*node.Message, error) {
+	win.emitted = true
+	payloads := make([][]byte, len(win.events))
+	for i, e := range win.events {
+		payloads[i] = e.msg.Payload
+	}
+	value, err := w.cfg.Aggregate(payloads)
+	if err != nil {
+		return nil, fmt.Errorf("stream: window %s [%s, %s): %w", w.GetID(), win.start.Format(time.RFC3339Nano), win.end.Format(time.RFC3339Nano), err)
+	}
+	payload, err := json.Marshal(WindowResult{
+		Key:    win.key,
+		Start:  win.start,
+		End:    win.end,
+		Count:  len(win.events),
+		Value:  rawJSON(value),
+		Update: update,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := win.events[len(win.events)-1].msg.Derive(payload)
+	out.Timestamp = win.end
+	out.ContentType = "application/json"
+	return out, nil
+}
@@ -0,0 +1,58 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// Factory creates a node of a registered kind from its ID and options.
+type Factory func(id string, opts Options) (node.Node, error)
+
+var (
+	kindsMu sync.RWMutex
+	kinds   = make(map[string]Factory)
+)
+
+func init() {
+	RegisterKind("base", func(id string, opts Options) (node.Node, error) {
+		if !opts.IsZero() {
+			return nil, fmt.Errorf("kind base takes no options")
+		}
+		return node.NewBaseNode(id), nil
+	})
+}
+
+// RegisterKind makes a node kind available to topology files. It panics if
+// the kind is already registered or factory is nil.
+func RegisterKind(kind string, factory Factory) {
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+	if factory == nil {
+		panic("topology: RegisterKind factory is nil")
+	}
+	if _, dup := kinds[kind]; dup {
+		panic("topology: RegisterKind called twice for kind " + kind)
+	}
+	kinds[kind] = factory
+}
+
+// Kinds returns the names of the registered kinds, sorted.
+func Kinds() []string {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupKind(kind string) Factory {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	return kinds[kind]
+}
@@ -0,0 +1,95 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lhemerly/Constellation/node"
+)
+
+// Load parses a topology file, builds it and starts the resulting runtime.
+func Load(path string) (*node.Runtime, error) {
+	t, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := t.Build()
+	if err != nil {
+		return nil, err
+	}
+	if err := rt.Start(); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Build validates the topology and instantiates its nodes and
+// subscriptions in a runtime that has not been started.
+func (t *Topology) Build() (*node.Runtime, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	errorf := func(line int, format string, args ...interface{}) {
+		errs = append(errs, &Error{File: t.File, Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	rt := node.NewRuntime()
+	for _, spec := range t.Nodes {
+		n, err := lookupKind(spec.Kind)(spec.ID, spec.Options)
+		if err != nil {
+			errorf(spec.Line, "node %q: %v", spec.ID, err)
+			continue
+		}
+		if n.GetID() != spec.ID {
+			errorf(spec.Line, "node %q: kind %s created a node with id %q", spec.ID, spec.Kind, n.GetID())
+			continue
+		}
+		if err := rt.Add(n); err != nil {
+			errorf(spec.Line, "%v", err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for _, spec := range t.Subscriptions {
+		opts, err := spec.options()
+		if err == nil {
+			err = rt.Connect(spec.From, spec.To, opts...)
+		}
+		if err != nil {
+			errorf(spec.Line, "subscription %s -> %s: %v", spec.From, spec.To, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return rt, nil
+}
+
+// options converts the spec into subscription options.
+func (s SubscriptionSpec) options() ([]node.SubscriptionOption, error) {
+	var opts []node.SubscriptionOption
+	if s.Filter != "" {
+		opts = append(opts, node.WithFilterExpr(s.Filter))
+	}
+	if r := s.Retry; r != nil {
+		opts = append(opts, node.WithRetry(node.RetryPolicy{
+			MaxAttempts:    r.MaxAttempts,
+			InitialBackoff: r.InitialBackoff,
+			MaxBackoff:     r.MaxBackoff,
+			Multiplier:     r.Multiplier,
+			Jitter:         r.Jitter,
+		}))
+	}
+	if r := s.RateLimit; r != nil {
+		mode, err := rateLimitMode(r.Mode)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, node.WithRateLimit(node.RateLimit{Rate: r.Rate, Burst: r.Burst, Mode: mode}))
+	}
+	return opts, nil
+}
@@ -0,0 +1,207 @@
+package topology_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lhemerly/Constellation/node"
+	"github.com/lhemerly/Constellation/topology"
+)
+
+// recorder collects the payloads it processes.
+type recorder struct {
+	*node.BaseNode
+	mu     sync.Mutex
+	events []string
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = make(map[string]*recorder)
+)
+
+func init() {
+	topology.RegisterKind("prefix", func(id string, opts topology.Options) (node.Node, error) {
+		var cfg struct {
+			Prefix string `yaml:"prefix"`
+		}
+		if err := opts.Decode(&cfg); err != nil {
+			return nil, err
+		}
+		n := node.NewBaseNode(id)
+		n.SetMessageFunc(func(msg *node.Message) (*node.Message, error) {
+			out := msg.Derive([]byte(cfg.Prefix + string(msg.Payload)))
+			return out, n.NotifyMessage(out)
+		})
+		return n, nil
+	})
+	topology.RegisterKind("recorder", func(id string, opts topology.Options) (node.Node, error) {
+		r := &recorder{BaseNode: node.NewBaseNode(id)}
+		r.SetProcessFunc(func(input []byte) ([]byte, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.events = append(r.events, string(input))
+			return input, nil
+		})
+		recordersMu.Lock()
+		recorders[id] = r
+		recordersMu.Unlock()
+		return r, nil
+	})
+}
+
+const pipelineYAML = `nodes:
+  - id: ingest
+    kind: base
+  - id: tag
+    kind: prefix
+    options:
+      prefix: "hot:"
+  - id: yaml-out
+    kind: recorder
+subscriptions:
+  - from: ingest
+    to: tag
+    filter: 'temp > 25'
+    retry:
+      max_attempts: 3
+      initial_backoff: 10ms
+  - from: tag
+    to: yaml-out
+`
+
+func TestLoadYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	if err := os.WriteFile(path, []byte(pipelineYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, err := topology.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	defer rt.Shutdown(context.Background())
+
+	if got := strings.Join(rt.IDs(), ","); got != "ingest,tag,yaml-out" {
+		t.Errorf("IDs() = %s", got)
+	}
+	ingest := rt.Get("ingest").(*node.BaseNode)
+	for _, event := range []string{`{"temp": 30}`, `{"temp": 20}`} {
+		if err := ingest.Notify([]byte(event)); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+	out := recorders["yaml-out"]
+	if len(out.events) != 1 || out.events[0] != `hot:{"temp": 30}` {
+		t.Errorf("recorded = %q, want only the filtered, prefixed event", out.events)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	doc := `{
+  "nodes": [
+    {"id": "a", "kind": "base"},
+    {"id": "json-out", "kind": "recorder"}
+  ],
+  "subscriptions": [
+    {"from": "a", "to": "json-out", "rate_limit": {"rate": 100, "mode": "reject"}}
+  ]
+}`
+	topo, err := topology.Parse([]byte(doc), "graph.json")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(topo.Nodes) != 2 || topo.Nodes[1].Line != 4 {
+		t.Errorf("Nodes = %+v, want 2 with the second on line 4", topo.Nodes)
+	}
+	sub := topo.Subscriptions[0]
+	if sub.RateLimit == nil || sub.RateLimit.Rate != 100 || sub.RateLimit.Mode != "reject" {
+		t.Errorf("RateLimit = %+v", sub.RateLimit)
+	}
+	rt, err := topo.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if rt.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", rt.Len())
+	}
+}
+
+func TestParseReportsLines(t *testing.T) {
+	doc := `nodes:
+  - id: a
+    kind: base
+  - id: a
+    kind: base
+  - id: b
+    kind: warp-drive
+  - id: c
+    kind: base
+    colour: blue
+subscriptions:
+  - from: a
+    to: ghost
+  - from: a
+    to: c
+    filter: 'temp >'
+  - from: a
+    to: c
+    retry:
+      initial_backoff: soon
+`
+	_, err := topology.Parse([]byte(doc), "bad.yaml")
+	if err == nil {
+		t.Fatal("Parse() error = nil")
+	}
+	for _, want := range []string{
+		`bad.yaml:10: unknown field "colour"`,
+		"bad.yaml:20: retry: cannot unmarshal !!str `soon` into time.Duration",
+		`bad.yaml:4: duplicate node id "a" (first declared on line 2)`,
+		`bad.yaml:6: node "b": unknown kind "warp-drive"`,
+		`bad.yaml:12: subscription to: unknown node "ghost"`,
+		`bad.yaml:14: subscription a -> c: filter:`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	var terr *topology.Error
+	if !errors.As(err, &terr) || terr.File != "bad.yaml" || terr.Line == 0 {
+		t.Errorf("errors.As(*topology.Error) = %+v", terr)
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	_, err := topology.Parse([]byte("nodes:\n  - id: a\n    kind: [base\n"), "broken.yaml")
+	var terr *topology.Error
+	if !errors.As(err, &terr) || terr.Line == 0 {
+		t.Errorf("Parse() error = %v, want *topology.Error with a line", err)
+	}
+}
+
+func TestBuildReportsFactoryErrors(t *testing.T) {
+	doc := `nodes:
+  - id: a
+    kind: base
+    options:
+      anything: 1
+`
+	topo, err := topology.Parse([]byte(doc), "opts.yaml")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := topo.Build(); err == nil || !strings.Contains(err.Error(), "opts.yaml:2: node \"a\": kind base takes no options") {
+		t.Errorf("Build() error = %v", err)
+	}
+}
+
+func TestKinds(t *testing.T) {
+	kinds := strings.Join(topology.Kinds(), ",")
+	if kinds != "base,prefix,recorder" {
+		t.Errorf("Kinds() = %s", kinds)
+	}
+}
@@ -0,0 +1,369 @@
+// Package topology describes node graphs declaratively and instantiates
+// them.
+//
+// A topology file, written in YAML or JSON, lists nodes by ID and kind and
+// the subscriptions between them:
+//
+//	nodes:
+//	  - id: sensors
+//	    kind: base
+//	  - id: alerts
+//	    kind: threshold
+//	    options:
+//	      limit: 30
+//	subscriptions:
+//	  - from: sensors
+//	    to: alerts
+//	    filter: 'temp > 25'
+//	    retry:
+//	      max_attempts: 3
+//	      initial_backoff: 100ms
+//
+// Node kinds map to factories registered with RegisterKind; the kind "base"
+// is built in and creates an echoing node.BaseNode. Each node's options are
+// passed to its factory. Parse validates a file, reporting problems with the
+// file name and line, and Load builds and starts it as a node.Runtime.
+package topology
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lhemerly/Constellation/node"
+	"gopkg.in/yaml.v3"
+)
+
+// Topology is a parsed node graph.
+type Topology struct {
+	// File is the name used in validation errors.
+	File          string
+	Nodes         []NodeSpec
+	Subscriptions []SubscriptionSpec
+}
+
+// NodeSpec describes a node.
+type NodeSpec struct {
+	ID      string
+	Kind    string
+	Options Options
+	// Line is the line the node is declared on, or zero.
+	Line int
+}
+
+// SubscriptionSpec describes a subscription of node To to node From.
+type SubscriptionSpec struct {
+	From string
+	To   string
+	// Filter is a node.CompileFilter expression. Empty means all events.
+	Filter    string
+	Retry     *RetrySpec
+	RateLimit *RateLimitSpec
+	// Line is the line the subscription is declared on, or zero.
+	Line int
+}
+
+// RetrySpec mirrors node.RetryPolicy.
+type RetrySpec struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	Jitter         float64       `yaml:"jitter"`
+}
+
+// RateLimitSpec mirrors node.RateLimit. Mode is "wait", "reject" or
+// "sample"; empty means wait.
+type RateLimitSpec struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+	Mode  string  `yaml:"mode"`
+}
+
+// Options holds a node's kind-specific options.
+type Options struct {
+	node *yaml.Node
+}
+
+// Decode decodes the options into v, typically a pointer to a struct with
+// yaml field tags. Decoding empty options leaves v unchanged.
+func (o Options) Decode(v interface{}) error {
+	if o.node == nil {
+		return nil
+	}
+	return o.node.Decode(v)
+}
+
+// IsZero reports whether no options were given.
+func (o Options) IsZero() bool {
+	return o.node == nil || len(o.node.Content) == 0
+}
+
+// Error is a validation error at a position in a topology file.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return e.Msg
+}
+
+// ParseFile reads and parses a topology file.
+func ParseFile(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, path)
+}
+
+// Parse parses and validates a topology in YAML or JSON. File is used in
+// error messages. All problems found are returned joined together as
+// *Error values.
+func Parse(data []byte, file string) (*Topology, error) {
+	t := &Topology{File: file}
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		syntaxErr := &Error{File: file, Msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if _, scanErr := fmt.Sscanf(syntaxErr.Msg, "line %d:", &syntaxErr.Line); scanErr == nil {
+			syntaxErr.Msg = strings.TrimSpace(syntaxErr.Msg[strings.Index(syntaxErr.Msg, ":")+1:])
+		}
+		return nil, syntaxErr
+	}
+	if len(doc.Content) == 0 {
+		return nil, &Error{File: file, Msg: "empty topology"}
+	}
+
+	p := &parser{file: file}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, p.errorf(root, "topology must be a mapping")
+	}
+	p.checkKeys(root, "nodes", "subscriptions")
+	if nodes := mapValue(root, "nodes"); nodes != nil {
+		for _, n := range p.sequence(nodes, "nodes") {
+			if spec, ok := p.nodeSpec(n); ok {
+				t.Nodes = append(t.Nodes, spec)
+			}
+		}
+	}
+	if subs := mapValue(root, "subscriptions"); subs != nil {
+		for _, n := range p.sequence(subs, "subscriptions") {
+			if spec, ok := p.subscriptionSpec(n); ok {
+				t.Subscriptions = append(t.Subscriptions, spec)
+			}
+		}
+	}
+	if err := errors.Join(append(p.errs, t.Validate())...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Validate checks that node IDs are present and unique, kinds are
+// registered, subscriptions refer to declared nodes, and filters and rate
+// limit modes are valid.
+func (t *Topology) Validate() error {
+	var errs []error
+	errorf := func(line int, format string, args ...interface{}) {
+		errs = append(errs, &Error{File: t.File, Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	declared := make(map[string]int, len(t.Nodes))
+	for _, spec := range t.Nodes {
+		if spec.ID == "" {
+			errorf(spec.Line, "node has no id")
+			continue
+		}
+		if line, ok := declared[spec.ID]; ok {
+			errorf(spec.Line, "duplicate node id %q (first declared on line %d)", spec.ID, line)
+			continue
+		}
+		declared[spec.ID] = spec.Line
+		if spec.Kind == "" {
+			errorf(spec.Line, "node %q has no kind", spec.ID)
+		} else if lookupKind(spec.Kind) == nil {
+			errorf(spec.Line, "node %q: unknown kind %q", spec.ID, spec.Kind)
+		}
+	}
+
+	for _, spec := range t.Subscriptions {
+		for _, end := range []struct{ field, id string }{{"from", spec.From}, {"to", spec.To}} {
+			if end.id == "" {
+				errorf(spec.Line, "subscription has no %s", end.field)
+			} else if _, ok := declared[end.id]; !ok {
+				errorf(spec.Line, "subscription %s: unknown node %q", end.field, end.id)
+			}
+		}
+		if spec.Filter != "" {
+			if _, err := node.CompileFilter(spec.Filter); err != nil {
+				errorf(spec.Line, "subscription %s -> %s: filter: %v", spec.From, spec.To, err)
+			}
+		}
+		if spec.RateLimit != nil {
+			if _, err := rateLimitMode(spec.RateLimit.Mode); err != nil {
+				errorf(spec.Line, "subscription %s -> %s: %v", spec.From, spec.To, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parser converts a YAML document into specs, collecting errors.
+type parser struct {
+	file string
+	errs []error
+}
+
+func (p *parser) errorf(n *yaml.Node, format string, args ...interface{}) error {
+	err := &Error{File: p.file, Line: n.Line, Msg: fmt.Sprintf(format, args...)}
+	p.errs = append(p.errs, err)
+	return err
+}
+
+// checkKeys reports keys of a mapping that are not in allowed.
+func (p *parser) checkKeys(n *yaml.Node, allowed ...string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		known := false
+		for _, a := range allowed {
+			if key.Value == a {
+				known = true
+				break
+			}
+		}
+		if !known {
+			p.errorf(key, "unknown field %q", key.Value)
+		}
+	}
+}
+
+// sequence returns the items of a sequence node.
+func (p *parser) sequence(n *yaml.Node, field string) []*yaml.Node {
+	if n.Kind != yaml.SequenceNode {
+		p.errorf(n, "%s must be a list", field)
+		return nil
+	}
+	return n.Content
+}
+
+// scalar returns the string value of a mapping key, or "" if it is absent.
+func (p *parser) scalar(n *yaml.Node, key string) string {
+	v := mapValue(n, key)
+	if v == nil {
+		return ""
+	}
+	if v.Kind != yaml.ScalarNode {
+		p.errorf(v, "%s must be a string", key)
+		return ""
+	}
+	return v.Value
+}
+
+func (p *parser) nodeSpec(n *yaml.Node) (NodeSpec, bool) {
+	if n.Kind != yaml.MappingNode {
+		p.errorf(n, "node must be a mapping")
+		return NodeSpec{}, false
+	}
+	p.checkKeys(n, "id", "kind", "options")
+	spec := NodeSpec{
+		ID:   p.scalar(n, "id"),
+		Kind: p.scalar(n, "kind"),
+		Line: n.Line,
+	}
+	if opts := mapValue(n, "options"); opts != nil {
+		if opts.Kind != yaml.MappingNode {
+			p.errorf(opts, "options must be a mapping")
+		} else {
+			spec.Options = Options{node: opts}
+		}
+	}
+	return spec, true
+}
+
+func (p *parser) subscriptionSpec(n *yaml.Node) (SubscriptionSpec, bool) {
+	if n.Kind != yaml.MappingNode {
+		p.errorf(n, "subscription must be a mapping")
+		return SubscriptionSpec{}, false
+	}
+	p.checkKeys(n, "from", "to", "filter", "retry", "rate_limit")
+	spec := SubscriptionSpec{
+		From:   p.scalar(n, "from"),
+		To:     p.scalar(n, "to"),
+		Filter: p.scalar(n, "filter"),
+		Line:   n.Line,
+	}
+	if v := mapValue(n, "retry"); v != nil {
+		p.checkKeys(v, "max_attempts", "initial_backoff", "max_backoff", "multiplier", "jitter")
+		spec.Retry = &RetrySpec{}
+		if err := v.Decode(spec.Retry); err != nil {
+			p.decodeError(v, "retry", err)
+		}
+	}
+	if v := mapValue(n, "rate_limit"); v != nil {
+		p.checkKeys(v, "rate", "burst", "mode")
+		spec.RateLimit = &RateLimitSpec{}
+		if err := v.Decode(spec.RateLimit); err != nil {
+			p.decodeError(v, "rate_limit", err)
+		}
+	}
+	return spec, true
+}
+
+// mapValue returns the value of key in a mapping node, or nil.
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// decodeError reports an error decoding field n. Type errors name the line
+// of the offending value, which is reported in place of n's line.
+func (p *parser) decodeError(n *yaml.Node, field string, err error) {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		p.errorf(n, "%s: %v", field, err)
+		return
+	}
+	for _, msg := range typeErr.Errors {
+		line := n.Line
+		if i := strings.Index(msg, ": "); i > 0 {
+			if _, err := fmt.Sscanf(msg[:i], "line %d", &line); err == nil {
+				msg = msg[i+2:]
+			}
+		}
+		p.errs = append(p.errs, &Error{File: p.file, Line: line, Msg: field + ": " + msg})
+	}
+}
+
+// rateLimitMode parses a RateLimitSpec mode.
+func rateLimitMode(mode string) (node.RateLimitMode, error) {
+	switch mode {
+	case "", "wait":
+		return node.RateLimitWait, nil
+	case "reject":
+		return node.RateLimitReject, nil
+	case "sample":
+		return node.RateLimitSample, nil
+	}
+	return 0, fmt.Errorf("unknown rate limit mode %q", mode)
+}